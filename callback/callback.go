@@ -0,0 +1,211 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+// Package callback provides the listeners that receive the out-of-band
+// callbacks made by the filter uploaded during active scans, over HTTP or
+// DNS, and correlates them with the scan that uploaded it.
+package callback
+
+import (
+	"context"
+	"crypto/rand"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+	"sync"
+
+	gozuul "github.com/adevinta/gozuul"
+)
+
+const callbackPath = "/callback/"
+
+// ErrUnspecifiedAddr is returned by Listen when no hostport is given and the
+// listen address has no specific host.
+var ErrUnspecifiedAddr = errors.New("the targets can not reach an unspecified listen address")
+
+// Scan is a scan registered in a Server. The URL must be injected in the
+// filter uploaded to the Target, and C receives a value when the callback is
+// received.
+type Scan struct {
+	Target string
+	Token  string
+	URL    string
+	C      chan bool
+}
+
+// registry keeps the scans waiting for a callback, indexed by token.
+type registry struct {
+	mu    sync.Mutex
+	scans map[string]*Scan
+}
+
+func newRegistry() *registry {
+	return &registry{scans: make(map[string]*Scan)}
+}
+
+// register registers a new scan against the target. The callback URL of the
+// scan is built from its token by the url function.
+func (r *registry) register(target string, url func(token string) string) (*Scan, error) {
+	token, err := newToken()
+	if err != nil {
+		return nil, err
+	}
+
+	scan := &Scan{
+		Target: target,
+		Token:  token,
+		URL:    url(token),
+		C:      make(chan bool, 1),
+	}
+
+	r.mu.Lock()
+	r.scans[token] = scan
+	r.mu.Unlock()
+
+	return scan, nil
+}
+
+func (r *registry) unregister(token string) {
+	r.mu.Lock()
+	delete(r.scans, token)
+	r.mu.Unlock()
+}
+
+// notify notifies the scan with the given token that its callback has been
+// received. It returns false if there is no such scan.
+func (r *registry) notify(token string) bool {
+	r.mu.Lock()
+	scan, ok := r.scans[token]
+	r.mu.Unlock()
+
+	if !ok {
+		return false
+	}
+
+	// The channel is buffered, so a single pending notification is enough
+	// no matter how many times the filter calls back.
+	select {
+	case scan.C <- true:
+	default:
+	}
+
+	return true
+}
+
+// activeScan registers a new scan against the target and executes it using
+// the given gozuul.Scanner, or the default one when nil.
+func (r *registry) activeScan(ctx context.Context, scanner *gozuul.Scanner, target string, url func(token string) string) (gozuul.ResultSet, error) {
+	scan, err := r.register(target, url)
+	if err != nil {
+		return gozuul.ResultSet{}, err
+	}
+	defer r.unregister(scan.Token)
+
+	if scanner == nil {
+		return gozuul.ActiveScanContext(ctx, target, scan.URL, scan.C)
+	}
+	return scanner.ActiveScanContext(ctx, target, scan.URL, scan.C)
+}
+
+// Server listens for the callbacks made by the uploaded filters over HTTP.
+type Server struct {
+	*registry
+
+	hostport string
+	ln       net.Listener
+	srv      *http.Server
+}
+
+// Listen starts a new Server listening in addr. The hostport parameter is the
+// host:port the targets must use to reach the Server. When it is empty the
+// address the Server listens in is used, so addr must have a specific host:
+// the targets can not reach a wildcard address such as ":8080" or
+// "0.0.0.0:8080".
+func Listen(addr, hostport string) (*Server, error) {
+	if hostport == "" {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		if ip := net.ParseIP(host); host == "" || (ip != nil && ip.IsUnspecified()) {
+			return nil, fmt.Errorf("%w: %q", ErrUnspecifiedAddr, addr)
+		}
+	}
+
+	ln, err := net.Listen("tcp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	if hostport == "" {
+		hostport = ln.Addr().String()
+	}
+
+	s := &Server{
+		registry: newRegistry(),
+		hostport: hostport,
+		ln:       ln,
+	}
+
+	mux := http.NewServeMux()
+	mux.HandleFunc(callbackPath, s.handleCallback)
+	s.srv = &http.Server{Handler: mux}
+
+	go s.srv.Serve(ln)
+
+	return s, nil
+}
+
+// Addr returns the address the Server listens in.
+func (s *Server) Addr() string {
+	return s.ln.Addr().String()
+}
+
+// Close stops the Server.
+func (s *Server) Close() error {
+	return s.srv.Close()
+}
+
+// Register registers a new scan against the target, generating the unique
+// token and callback URL for it.
+func (s *Server) Register(target string) (*Scan, error) {
+	return s.register(target, s.callbackURL)
+}
+
+// Unregister removes the scan with the given token from the Server. Later
+// callbacks for it are ignored.
+func (s *Server) Unregister(token string) {
+	s.unregister(token)
+}
+
+// ActiveScan registers a new scan against the target and executes it using
+// the given gozuul.Scanner, or the default one when nil.
+func (s *Server) ActiveScan(ctx context.Context, scanner *gozuul.Scanner, target string) (gozuul.ResultSet, error) {
+	return s.activeScan(ctx, scanner, target, s.callbackURL)
+}
+
+func (s *Server) callbackURL(token string) string {
+	return "http://" + s.hostport + callbackPath + token
+}
+
+func (s *Server) handleCallback(w http.ResponseWriter, r *http.Request) {
+	token := strings.TrimPrefix(r.URL.Path, callbackPath)
+
+	if !s.notify(token) {
+		http.NotFound(w, r)
+	}
+}
+
+// newToken returns a new random scan token.
+func newToken() (string, error) {
+	b := make([]byte, 16)
+	if _, err := rand.Read(b); err != nil {
+		return "", fmt.Errorf("unable to generate scan token: %w", err)
+	}
+
+	return hex.EncodeToString(b), nil
+}
@@ -0,0 +1,141 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package callback
+
+import (
+	"context"
+	"errors"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	gozuul "github.com/adevinta/gozuul"
+)
+
+var callbackRe = regexp.MustCompile(`new URL\("([^"]+)"\)`)
+
+// executingZuul returns a fake vulnerable Zuul that executes the callback of
+// the uploaded filters.
+func executingZuul(t *testing.T) *httptest.Server {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/vulncheck-spt", func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+	mux.HandleFunc("/admin/filterLoader.jsp", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/admin/scriptmanager", func(w http.ResponseWriter, r *http.Request) {
+		f, _, err := r.FormFile("upload")
+		if err != nil {
+			t.Errorf("uploaded filter expected, got error: %v", err)
+			return
+		}
+		src, _ := ioutil.ReadAll(f)
+
+		m := callbackRe.FindSubmatch(src)
+		if m == nil {
+			t.Errorf("callback URL expected in the uploaded filter")
+			return
+		}
+		res, err := http.Get(string(m[1]))
+		if err != nil {
+			t.Errorf("nil error expected calling back, got: %v", err)
+			return
+		}
+		res.Body.Close()
+
+		w.Header().Set("Location", "http://donotfollow.example.com")
+		w.WriteHeader(http.StatusFound)
+	})
+
+	return httptest.NewServer(mux)
+}
+
+func TestActiveScan(t *testing.T) {
+	ts := executingZuul(t)
+	defer ts.Close()
+
+	s, err := Listen("127.0.0.1:0", "")
+	if err != nil {
+		t.Fatalf("nil error expected, got: %v", err)
+	}
+	defer s.Close()
+
+	rs, err := s.ActiveScan(context.Background(), nil, ts.URL)
+	if err != nil {
+		t.Fatalf("nil error expected, got: %v", err)
+	}
+	if rs.Verdict != gozuul.VerdictVulnerable {
+		t.Errorf("verdict expected: %v, got: %v (%v)", gozuul.VerdictVulnerable, rs.Verdict, rs.Reason)
+	}
+
+	s.mu.Lock()
+	pending := len(s.scans)
+	s.mu.Unlock()
+	if pending != 0 {
+		t.Errorf("no registered scans expected after the scan, got: %v", pending)
+	}
+}
+
+func TestListenUnspecified(t *testing.T) {
+	for _, addr := range []string{":0", "0.0.0.0:0", "[::]:0"} {
+		if s, err := Listen(addr, ""); !errors.Is(err, ErrUnspecifiedAddr) {
+			if err == nil {
+				s.Close()
+			}
+			t.Errorf("error expected listening in %q without hostport: %v, got: %v", addr, ErrUnspecifiedAddr, err)
+		}
+
+		s, err := Listen(addr, "zuul-callbacks.example.com:8080")
+		if err != nil {
+			t.Errorf("nil error expected listening in %q with hostport, got: %v", addr, err)
+			continue
+		}
+		s.Close()
+	}
+}
+
+func TestCallbackCorrelation(t *testing.T) {
+	s, err := Listen("127.0.0.1:0", "zuul-callbacks.example.com:8080")
+	if err != nil {
+		t.Fatalf("nil error expected, got: %v", err)
+	}
+	defer s.Close()
+
+	a, _ := s.Register("http://a.example.com")
+	b, _ := s.Register("http://b.example.com")
+	if a.Token == b.Token {
+		t.Fatalf("unique tokens expected, got: %v", a.Token)
+	}
+	if want := "http://zuul-callbacks.example.com:8080/callback/" + b.Token; b.URL != want {
+		t.Errorf("callback URL expected: %v, got: %v", want, b.URL)
+	}
+
+	res, err := http.Get("http://" + s.Addr() + "/callback/" + b.Token)
+	if err != nil {
+		t.Fatalf("nil error expected, got: %v", err)
+	}
+	res.Body.Close()
+
+	select {
+	case <-a.C:
+		t.Errorf("callback not expected for %v", a.Target)
+	default:
+	}
+	select {
+	case <-b.C:
+	default:
+		t.Errorf("callback expected for %v", b.Target)
+	}
+
+	res, err = http.Get("http://" + s.Addr() + "/callback/unknown")
+	if err != nil {
+		t.Fatalf("nil error expected, got: %v", err)
+	}
+	res.Body.Close()
+	if res.StatusCode != http.StatusNotFound {
+		t.Errorf("status expected: %v, got: %v", http.StatusNotFound, res.StatusCode)
+	}
+}
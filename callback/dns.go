@@ -0,0 +1,235 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package callback
+
+import (
+	"context"
+	"encoding/binary"
+	"errors"
+	"net"
+	"strings"
+
+	gozuul "github.com/adevinta/gozuul"
+)
+
+const (
+	dnsHeaderLen = 12
+
+	dnsTypeA   = 1
+	dnsClassIN = 1
+
+	dnsRcodeFormErr = 1
+	dnsRcodeRefused = 5
+)
+
+var errMalformedQuery = errors.New("malformed DNS query")
+
+// DNSServer is a minimal authoritative DNS server for a zone delegated to it.
+// It listens for the lookups made by the DNS variant of the uploaded filter,
+// which resolves a unique subdomain of the zone for every scan.
+type DNSServer struct {
+	*registry
+
+	zone   string
+	answer net.IP
+	conn   net.PacketConn
+}
+
+// ListenDNS starts a new DNSServer listening in the UDP address addr, and
+// authoritative for the given zone. When answer is not nil, the A queries for
+// the names in the zone are answered with it. Otherwise the names are
+// reported to exist without any address.
+func ListenDNS(addr, zone string, answer net.IP) (*DNSServer, error) {
+	if answer != nil && answer.To4() == nil {
+		return nil, errors.New("answer must be an IPv4 address")
+	}
+
+	conn, err := net.ListenPacket("udp", addr)
+	if err != nil {
+		return nil, err
+	}
+
+	s := &DNSServer{
+		registry: newRegistry(),
+		zone:     canonicalName(zone),
+		answer:   answer.To4(),
+		conn:     conn,
+	}
+
+	go s.serve()
+
+	return s, nil
+}
+
+// Addr returns the address the DNSServer listens in.
+func (s *DNSServer) Addr() string {
+	return s.conn.LocalAddr().String()
+}
+
+// Close stops the DNSServer.
+func (s *DNSServer) Close() error {
+	return s.conn.Close()
+}
+
+// Register registers a new scan against the target, generating the unique
+// token and callback DNS URI for it.
+func (s *DNSServer) Register(target string) (*Scan, error) {
+	return s.register(target, s.callbackURL)
+}
+
+// Unregister removes the scan with the given token from the DNSServer. Later
+// lookups for it are ignored.
+func (s *DNSServer) Unregister(token string) {
+	s.unregister(token)
+}
+
+// ActiveScan registers a new scan against the target and executes it using
+// the given gozuul.Scanner, or the default one when nil.
+func (s *DNSServer) ActiveScan(ctx context.Context, scanner *gozuul.Scanner, target string) (gozuul.ResultSet, error) {
+	return s.activeScan(ctx, scanner, target, s.callbackURL)
+}
+
+func (s *DNSServer) callbackURL(token string) string {
+	return "dns:" + token + "." + s.zone
+}
+
+func (s *DNSServer) serve() {
+	buf := make([]byte, 512)
+
+	for {
+		n, addr, err := s.conn.ReadFrom(buf)
+		if err != nil {
+			// The connection has been closed.
+			return
+		}
+
+		res := s.handleQuery(buf[:n])
+		if res != nil {
+			s.conn.WriteTo(res, addr)
+		}
+	}
+}
+
+// handleQuery records the lookup contained in the query and returns the
+// response to send back, or nil if the query must be ignored.
+func (s *DNSServer) handleQuery(query []byte) []byte {
+	if len(query) < dnsHeaderLen {
+		return nil
+	}
+
+	// Ignore anything that is not a standard query.
+	flags := binary.BigEndian.Uint16(query[2:4])
+	if flags&0x8000 != 0 || (flags>>11)&0xf != 0 {
+		return nil
+	}
+
+	name, qtype, qclass, qend, err := parseQuestion(query)
+	if err != nil {
+		return dnsResponse(query, dnsHeaderLen, dnsRcodeFormErr, nil)
+	}
+
+	var sub string
+	switch {
+	case name == s.zone:
+	case strings.HasSuffix(name, "."+s.zone):
+		sub = strings.TrimSuffix(name, "."+s.zone)
+	default:
+		return dnsResponse(query, qend, dnsRcodeRefused, nil)
+	}
+
+	// Resolvers might prepend other labels, so the token is the label right
+	// before the zone.
+	if sub != "" {
+		labels := strings.Split(sub, ".")
+		s.notify(labels[len(labels)-1])
+	}
+
+	var answer net.IP
+	if qtype == dnsTypeA && qclass == dnsClassIN {
+		answer = s.answer
+	}
+
+	return dnsResponse(query, qend, 0, answer)
+}
+
+// parseQuestion parses the first question of a DNS query. It returns the
+// lowercased name, without the trailing dot, the type and class of the
+// question, and the offset where it ends.
+func parseQuestion(query []byte) (name string, qtype, qclass uint16, end int, err error) {
+	if binary.BigEndian.Uint16(query[4:6]) < 1 {
+		return "", 0, 0, 0, errMalformedQuery
+	}
+
+	var labels []string
+	off := dnsHeaderLen
+	for {
+		if off >= len(query) {
+			return "", 0, 0, 0, errMalformedQuery
+		}
+
+		l := int(query[off])
+		off++
+		if l == 0 {
+			break
+		}
+		// Compression pointers are not expected in questions.
+		if l&0xc0 != 0 || off+l > len(query) {
+			return "", 0, 0, 0, errMalformedQuery
+		}
+
+		labels = append(labels, string(query[off:off+l]))
+		off += l
+	}
+
+	if off+4 > len(query) {
+		return "", 0, 0, 0, errMalformedQuery
+	}
+	qtype = binary.BigEndian.Uint16(query[off : off+2])
+	qclass = binary.BigEndian.Uint16(query[off+2 : off+4])
+
+	return canonicalName(strings.Join(labels, ".")), qtype, qclass, off + 4, nil
+}
+
+// dnsResponse builds an authoritative response to the query, which question
+// ends at qend, with the given rcode and, if not nil, an A record answer.
+func dnsResponse(query []byte, qend int, rcode uint16, answer net.IP) []byte {
+	res := make([]byte, qend, qend+16)
+	copy(res, query[:qend])
+
+	// QR and AA set, keeping the opcode and RD of the query.
+	flags := binary.BigEndian.Uint16(query[2:4])
+	flags = 0x8000 | flags&0x7900 | 0x0400 | rcode
+	binary.BigEndian.PutUint16(res[2:4], flags)
+
+	qdcount := uint16(1)
+	if qend == dnsHeaderLen {
+		qdcount = 0
+	}
+	binary.BigEndian.PutUint16(res[4:6], qdcount)
+	binary.BigEndian.PutUint16(res[6:8], 0)
+	binary.BigEndian.PutUint16(res[8:10], 0)
+	binary.BigEndian.PutUint16(res[10:12], 0)
+
+	if answer == nil {
+		return res
+	}
+
+	binary.BigEndian.PutUint16(res[6:8], 1)
+	rr := make([]byte, 16)
+	// Pointer to the name of the question.
+	binary.BigEndian.PutUint16(rr[0:2], 0xc000|dnsHeaderLen)
+	binary.BigEndian.PutUint16(rr[2:4], dnsTypeA)
+	binary.BigEndian.PutUint16(rr[4:6], dnsClassIN)
+	// A TTL of 0, so every lookup reaches the server.
+	binary.BigEndian.PutUint32(rr[6:10], 0)
+	binary.BigEndian.PutUint16(rr[10:12], 4)
+	copy(rr[12:16], answer)
+
+	return append(res, rr...)
+}
+
+func canonicalName(name string) string {
+	return strings.ToLower(strings.TrimSuffix(name, "."))
+}
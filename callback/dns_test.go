@@ -0,0 +1,112 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package callback
+
+import (
+	"context"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"regexp"
+	"testing"
+
+	gozuul "github.com/adevinta/gozuul"
+)
+
+var lookupRe = regexp.MustCompile(`InetAddress.getByName\("([^"]+)"\)`)
+
+// dnsResolver returns a resolver that sends every query to the DNSServer.
+func dnsResolver(s *DNSServer) *net.Resolver {
+	return &net.Resolver{
+		PreferGo: true,
+		Dial: func(ctx context.Context, network, address string) (net.Conn, error) {
+			var d net.Dialer
+			return d.DialContext(ctx, "udp", s.Addr())
+		},
+	}
+}
+
+func TestDNSServer(t *testing.T) {
+	s, err := ListenDNS("127.0.0.1:0", "OOB.example.com.", net.ParseIP("192.0.2.1"))
+	if err != nil {
+		t.Fatalf("nil error expected, got: %v", err)
+	}
+	defer s.Close()
+
+	a, _ := s.Register("http://a.example.com")
+	b, _ := s.Register("http://b.example.com")
+	if want := "dns:" + b.Token + ".oob.example.com"; b.URL != want {
+		t.Errorf("callback URL expected: %v, got: %v", want, b.URL)
+	}
+
+	r := dnsResolver(s)
+	addrs, err := r.LookupHost(context.Background(), "Prefix."+b.Token+".oob.EXAMPLE.com")
+	if err != nil {
+		t.Fatalf("nil error expected, got: %v", err)
+	}
+	if len(addrs) != 1 || addrs[0] != "192.0.2.1" {
+		t.Errorf("addresses expected: [192.0.2.1], got: %v", addrs)
+	}
+
+	select {
+	case <-a.C:
+		t.Errorf("lookup not expected for %v", a.Target)
+	default:
+	}
+	select {
+	case <-b.C:
+	default:
+		t.Errorf("lookup expected for %v", b.Target)
+	}
+
+	if _, err := r.LookupHost(context.Background(), "www.example.org"); err == nil {
+		t.Errorf("error expected looking up a name outside the zone")
+	}
+}
+
+func TestDNSActiveScan(t *testing.T) {
+	s, err := ListenDNS("127.0.0.1:0", "oob.example.com", nil)
+	if err != nil {
+		t.Fatalf("nil error expected, got: %v", err)
+	}
+	defer s.Close()
+	r := dnsResolver(s)
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/vulncheck-spt", func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+	mux.HandleFunc("/admin/filterLoader.jsp", func(w http.ResponseWriter, r *http.Request) {})
+	mux.HandleFunc("/admin/scriptmanager", func(w http.ResponseWriter, req *http.Request) {
+		f, _, err := req.FormFile("upload")
+		if err != nil {
+			t.Errorf("uploaded filter expected, got error: %v", err)
+			return
+		}
+		src, _ := ioutil.ReadAll(f)
+
+		m := lookupRe.FindSubmatch(src)
+		if m == nil {
+			t.Errorf("DNS lookup expected in the uploaded filter")
+			return
+		}
+		// The name exists without addresses, so the lookup fails.
+		r.LookupHost(req.Context(), string(m[1]))
+
+		w.Header().Set("Location", "http://donotfollow.example.com")
+		w.WriteHeader(http.StatusFound)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	rs, err := s.ActiveScan(context.Background(), nil, ts.URL)
+	if err != nil {
+		t.Fatalf("nil error expected, got: %v", err)
+	}
+	if rs.Verdict != gozuul.VerdictVulnerable {
+		t.Errorf("verdict expected: %v, got: %v (%v)", gozuul.VerdictVulnerable, rs.Verdict, rs.Reason)
+	}
+}
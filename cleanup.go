@@ -0,0 +1,148 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package gozuul
+
+import (
+	"context"
+	"fmt"
+)
+
+// CleanupStatus is the outcome of a cleanup.
+type CleanupStatus string
+
+// Outcomes of a cleanup.
+const (
+	// CleanupNothingFound means that the Vulncheck filter was neither listed
+	// nor serving the check endpoint.
+	CleanupNothingFound CleanupStatus = "nothing_found"
+	// CleanupCleaned means that the Vulncheck filter stopped serving the
+	// check endpoint after deactivating its revisions.
+	CleanupCleaned CleanupStatus = "cleaned"
+	// CleanupIncomplete means that the Vulncheck filter did not serve the
+	// check endpoint after deactivating its revisions, but some of them could
+	// not be deactivated.
+	CleanupIncomplete CleanupStatus = "incomplete"
+	// CleanupStillEnabled means that the Vulncheck filter kept serving the
+	// check endpoint after deactivating its revisions. Restarting the target
+	// may be needed.
+	CleanupStillEnabled CleanupStatus = "still_enabled"
+)
+
+// CleanupResult contains the details of a cleanup.
+// EnabledBefore and EnabledAfter indicate whether the Vulncheck filter served
+// the check endpoint before and after deactivating the Revisions of the
+// filter listed by the target. Deactivated are the revisions deactivated, and
+// Failures the ones that could not be deactivated.
+// Prefix and Evidence are set as in ResultSet.
+type CleanupResult struct {
+	Status        CleanupStatus    `json:"status"`
+	EnabledBefore bool             `json:"enabled_before"`
+	EnabledAfter  bool             `json:"enabled_after"`
+	Revisions     []int            `json:"revisions"`
+	Deactivated   []int            `json:"deactivated"`
+	Failures      []CleanupFailure `json:"failures,omitempty"`
+	Prefix        string           `json:"prefix,omitempty"`
+	Evidence      []Exchange       `json:"evidence,omitempty"`
+}
+
+// CleanupFailure is a revision of the Vulncheck filter that could not be
+// deactivated.
+type CleanupFailure struct {
+	Revision int    `json:"revision"`
+	Error    string `json:"error"`
+}
+
+// Cleanup deactivates the Vulncheck filters left in the target using the
+// default Scanner.
+func Cleanup(target string) (CleanupResult, error) {
+	return defaultScanner.Cleanup(target)
+}
+
+// CleanupContext is like Cleanup but honors the cancellation and deadline of
+// the given context.
+func CleanupContext(ctx context.Context, target string) (CleanupResult, error) {
+	return defaultScanner.CleanupContext(ctx, target)
+}
+
+// Cleanup deactivates the Vulncheck filters left in the target.
+func (s *Scanner) Cleanup(target string) (CleanupResult, error) {
+	return s.CleanupContext(context.Background(), target)
+}
+
+// CleanupContext deactivates every revision of the Vulncheck filter uploaded
+// by the active scans that is listed by the target, and waits for the filter
+// to stop serving the check endpoint, as the active scans wait for it to
+// become enabled. Every HTTP request, and the wait, honors the cancellation
+// and deadline of the given context.
+func (s *Scanner) CleanupContext(ctx context.Context, target string) (cr CleanupResult, err error) {
+	ctx, rec := s.withRecorder(ctx)
+	defer func() {
+		cr.Evidence = rec.evidence()
+	}()
+
+	cr.Status = CleanupNothingFound
+	cr.Revisions = []int{}
+	cr.Deactivated = []int{}
+
+	if target == "" {
+		return cr, fmt.Errorf("target can not be empty, target: %s", target)
+	}
+
+	cr.Prefix, err = s.adminPrefix(ctx, target)
+	if err != nil {
+		return cr, err
+	}
+	base := target + cr.Prefix
+
+	cr.EnabledBefore, err = s.isFilterEnabled(ctx, PhaseCheckFilter, base+s.endpoints.Check)
+	if err != nil {
+		return cr, err
+	}
+	cr.EnabledAfter = cr.EnabledBefore
+
+	filters, err := s.listFilters(ctx, base+s.endpoints.FilterLoader)
+	if err != nil {
+		return cr, err
+	}
+	for _, f := range filters {
+		if f.ID != vcheckID {
+			continue
+		}
+		for _, r := range f.Revisions {
+			cr.Revisions = append(cr.Revisions, r.Revision)
+		}
+	}
+
+	if len(cr.Revisions) == 0 && !cr.EnabledBefore {
+		return cr, nil
+	}
+
+	for _, rev := range cr.Revisions {
+		if err := s.setFilterAction(ctx, base+s.endpoints.ScriptManager, vcheckID, "DEACTIVATE", rev); err != nil {
+			if ctx.Err() != nil {
+				return cr, err
+			}
+			cr.Failures = append(cr.Failures, CleanupFailure{Revision: rev, Error: err.Error()})
+			continue
+		}
+		cr.Deactivated = append(cr.Deactivated, rev)
+	}
+
+	cr.EnabledAfter, err = s.waitFilterEnabled(ctx, PhaseDeactivate, base+s.endpoints.Check, false)
+	if err != nil {
+		return cr, err
+	}
+
+	switch {
+	case cr.EnabledAfter:
+		cr.Status = CleanupStillEnabled
+	case len(cr.Failures) > 0:
+		cr.Status = CleanupIncomplete
+	default:
+		cr.Status = CleanupCleaned
+	}
+
+	return cr, nil
+}
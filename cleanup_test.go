@@ -0,0 +1,141 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package gozuul
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"sync"
+	"testing"
+	"time"
+)
+
+// vulncheckTarget simulates a target with the given revisions of the
+// Vulncheck filter. The check endpoint is served while any of the revisions in
+// active is active, and the revisions in failing can not be deactivated.
+type vulncheckTarget struct {
+	mu          sync.Mutex
+	revisions   []int
+	active      map[int]bool
+	failing     map[int]bool
+	deactivated []int
+}
+
+func (vt *vulncheckTarget) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	vt.mu.Lock()
+	defer vt.mu.Unlock()
+
+	switch r.URL.Path {
+	case "/admin/filterLoader.jsp":
+		fmt.Fprintf(w, dummyFilter, 1)
+		for _, rev := range vt.revisions {
+			fmt.Fprintf(w, vcheckFilter, rev)
+		}
+	case "/admin/scriptmanager":
+		rev := 0
+		fmt.Sscan(r.FormValue("revision"), &rev)
+		if r.Method != "POST" || r.FormValue("action") != "DEACTIVATE" || r.FormValue("filter_id") != vcheckID || vt.failing[rev] {
+			http.Error(w, "", 500)
+			return
+		}
+		vt.deactivated = append(vt.deactivated, rev)
+		delete(vt.active, rev)
+		w.Header().Set("Location", "http://donotfollow.example.com")
+		http.Error(w, "", 302)
+	case "/vulncheck-spt":
+		if len(vt.active) == 0 {
+			notFound(w, r)
+			return
+		}
+		fmt.Fprint(w, "vulnerable")
+	default:
+		notFound(w, r)
+	}
+}
+
+func TestCleanup(t *testing.T) {
+	defer func(d time.Duration) { filterWaitUnit = d }(filterWaitUnit)
+	filterWaitUnit = time.Millisecond
+
+	tests := []struct {
+		name            string
+		target          *vulncheckTarget
+		want            CleanupResult
+		wantDeactivated []int
+	}{
+		{
+			name:   "nothingFound",
+			target: &vulncheckTarget{},
+			want: CleanupResult{
+				Status:      CleanupNothingFound,
+				Revisions:   []int{},
+				Deactivated: []int{},
+			},
+		},
+		{
+			name:   "cleaned",
+			target: &vulncheckTarget{revisions: []int{1, 2}, active: map[int]bool{2: true}},
+			want: CleanupResult{
+				Status:        CleanupCleaned,
+				EnabledBefore: true,
+				Revisions:     []int{1, 2},
+				Deactivated:   []int{1, 2},
+			},
+			wantDeactivated: []int{1, 2},
+		},
+		{
+			name:   "deactivationFailure",
+			target: &vulncheckTarget{revisions: []int{1, 2}, active: map[int]bool{2: true}, failing: map[int]bool{1: true}},
+			want: CleanupResult{
+				Status:        CleanupIncomplete,
+				EnabledBefore: true,
+				Revisions:     []int{1, 2},
+				Deactivated:   []int{2},
+				Failures:      []CleanupFailure{{Revision: 1}},
+			},
+			wantDeactivated: []int{2},
+		},
+		{
+			name:   "stillEnabled",
+			target: &vulncheckTarget{revisions: []int{3}, active: map[int]bool{3: true}, failing: map[int]bool{3: true}},
+			want: CleanupResult{
+				Status:        CleanupStillEnabled,
+				EnabledBefore: true,
+				EnabledAfter:  true,
+				Revisions:     []int{3},
+				Deactivated:   []int{},
+				Failures:      []CleanupFailure{{Revision: 3}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			ts := httptest.NewServer(tt.target)
+			defer ts.Close()
+
+			cr, err := Cleanup(ts.URL)
+			if err != nil {
+				t.Fatalf("nil error expected, got %v", err)
+			}
+
+			// The errors of the failures depend on the URL of the server.
+			for i := range cr.Failures {
+				if cr.Failures[i].Error == "" {
+					t.Errorf("error expected in failure %+v", cr.Failures[i])
+				}
+				cr.Failures[i].Error = ""
+			}
+			if !reflect.DeepEqual(cr, tt.want) {
+				t.Errorf("result expected: %+v, got: %+v", tt.want, cr)
+			}
+			if !reflect.DeepEqual(tt.target.deactivated, tt.wantDeactivated) {
+				t.Errorf("deactivated revisions expected: %v, got: %v", tt.wantDeactivated, tt.target.deactivated)
+			}
+		})
+	}
+}
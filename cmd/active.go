@@ -0,0 +1,153 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package cmd
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net"
+
+	gozuul "github.com/adevinta/gozuul"
+	"github.com/adevinta/gozuul/callback"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	authorized   bool
+	concurrency  int
+	listenAddr   string
+	callbackHost string
+	dnsZone      string
+	dnsListen    string
+	dnsAnswer    string
+)
+
+// activeCmd represents the active command
+var activeCmd = &cobra.Command{
+	Use:   "active <target>...",
+	Short: "Executes a new active scan against the specified targets",
+	Long: `Executes a new active scan against the specified targets.
+
+The active scan uploads a filter to the targets that, when executed, calls back
+to a listener started by the command. It modifies the targets, so it must be
+explicitly authorized with the --authorized flag.
+
+The targets are specified as in the passive command.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 1 {
+			return fmt.Errorf("incorrect number of args, want 1 at least, got %v", len(args))
+		}
+
+		return activeScan(args...)
+	},
+}
+
+// activeBulkCmd represents the activebulk command
+var activeBulkCmd = &cobra.Command{
+	Use:   "activebulk <targets-file>",
+	Short: "Executes a new active scan against the targets specified in a file",
+	Long: `Executes a new active scan against the targets specified in a file.
+
+The active scan uploads a filter to the targets that, when executed, calls back
+to a listener started by the command. It modifies the targets, so it must be
+explicitly authorized with the --authorized flag.
+
+The targets are specified as in the passivebulk command.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return fmt.Errorf("incorrect number of args, want 1, got %v", len(args))
+		}
+
+		tf := args[0]
+
+		specs, err := readSpecs(tf)
+		if err != nil {
+			return err
+		}
+
+		return activeScan(specs...)
+	},
+}
+
+func init() {
+	for _, c := range []*cobra.Command{activeCmd, activeBulkCmd} {
+		c.Flags().BoolVar(&authorized, "authorized", false, "confirms that you are authorized to modify the targets")
+		c.Flags().IntVarP(&concurrency, "concurrency", "c", 10, "maximum number of targets scanned at the same time")
+		c.Flags().StringVar(&listenAddr, "listen", ":8080", "address of the HTTP callback listener")
+		c.Flags().StringVar(&callbackHost, "callback-host", "", "host:port the targets must use to reach the HTTP callback listener, required unless --listen has a specific host (default the listen address)")
+		c.Flags().StringVar(&dnsZone, "dns-zone", "", "zone delegated to the DNS callback listener. When set, the DNS callbacks are used instead of the HTTP ones")
+		c.Flags().StringVar(&dnsListen, "dns-listen", ":53", "address of the DNS callback listener")
+		c.Flags().StringVar(&dnsAnswer, "dns-answer", "", "IPv4 address used to answer the A queries received by the DNS callback listener")
+		addTargetFlags(c)
+
+		RootCmd.AddCommand(c)
+	}
+
+	addInputFormatFlag(activeBulkCmd)
+}
+
+// callbackListener is a listener of the callback package.
+type callbackListener interface {
+	ActiveScan(ctx context.Context, scanner *gozuul.Scanner, target string) (gozuul.ResultSet, error)
+	Close() error
+}
+
+// newCallbackListener starts the callback listener configured by the flags.
+func newCallbackListener() (callbackListener, error) {
+	if dnsZone == "" {
+		l, err := callback.Listen(listenAddr, callbackHost)
+		if errors.Is(err, callback.ErrUnspecifiedAddr) {
+			return nil, fmt.Errorf("%w, set --callback-host to the host:port the targets must use to reach the callback listener", err)
+		}
+		if err != nil {
+			return nil, err
+		}
+		return l, nil
+	}
+
+	var answer net.IP
+	if dnsAnswer != "" {
+		if answer = net.ParseIP(dnsAnswer); answer == nil {
+			return nil, fmt.Errorf("invalid DNS answer: %v", dnsAnswer)
+		}
+	}
+
+	return callback.ListenDNS(dnsListen, dnsZone, answer)
+}
+
+func activeScan(specs ...string) error {
+	if !authorized {
+		return errors.New("active scans modify the targets, confirm that you are authorized to scan them with --authorized")
+	}
+	if concurrency < 1 {
+		return fmt.Errorf("concurrency must be greater than 0, got %v", concurrency)
+	}
+
+	targets, unreachable, err := resolveTargets(specs, concurrency)
+	if err != nil {
+		return err
+	}
+
+	w, err := newResultWriter(true)
+	if err != nil {
+		return err
+	}
+
+	l, err := newCallbackListener()
+	if err != nil {
+		w.Close()
+		return err
+	}
+	defer l.Close()
+
+	scanner := newScanner()
+	results := scanTargets(targets, unreachable, concurrency, func(ctx context.Context, target string) (gozuul.ResultSet, error) {
+		return l.ActiveScan(ctx, scanner, target)
+	})
+
+	return writeResults(w, results)
+}
@@ -0,0 +1,242 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package cmd
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	gozuul "github.com/adevinta/gozuul"
+	"github.com/adevinta/gozuul/analysis"
+
+	"github.com/spf13/cobra"
+)
+
+var rulesFile string
+
+// analyzeCmd represents the analyze command
+var analyzeCmd = &cobra.Command{
+	Use:   "analyze <target|dir>...",
+	Short: "Flags suspicious constructs in the filters deployed in the specified targets",
+	Long: `Downloads the source of the filters deployed in the specified targets, as the
+dump-filters command does, and flags the suspicious constructs in them, such
+as running commands, fetching URLs, opening sockets, evaluating base64 decoded
+code or starting threads when the filter is loaded. Directories written by
+the dump-filters command can be analyzed instead of live targets.
+
+The built-in rules can be replaced with the ones in the JSON file given with
+--rules, for instance:
+
+  [
+    {
+      "id": "runtime-exec",
+      "description": "Runs a command using Runtime.exec",
+      "severity": "high",
+      "pattern": "\\bRuntime\\b.*\\.\\s*exec\\s*\\("
+    }
+  ]
+
+Every rule can also set "requires", a pattern the filter must contain for the
+rule to apply, and "scope", which can be "constructor" to only match the
+constructors of the filter. With --exit-code, gozuul exits with 2 if any
+filter is flagged.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 1 {
+			return fmt.Errorf("incorrect number of args, want 1 at least, got %v", len(args))
+		}
+
+		return analyze(args...)
+	},
+}
+
+func init() {
+	analyzeCmd.Flags().StringVar(&rulesFile, "rules", "", "JSON file with the rules used instead of the built-in ones")
+	RootCmd.AddCommand(analyzeCmd)
+}
+
+// filterAnalysis contains the findings in a revision of a filter, or the
+// error that prevented downloading it.
+type filterAnalysis struct {
+	Source   string             `json:"source"`
+	ID       string             `json:"id"`
+	Revision int                `json:"revision"`
+	File     string             `json:"file,omitempty"`
+	Findings []analysis.Finding `json:"findings"`
+	Error    string             `json:"error,omitempty"`
+}
+
+func analyze(sources ...string) error {
+	if outputFormat != "text" && outputFormat != "json" {
+		return fmt.Errorf("unsupported output format for analyze: %v", outputFormat)
+	}
+
+	rules := analysis.DefaultRules()
+	if rulesFile != "" {
+		f, err := os.Open(rulesFile)
+		if err != nil {
+			return err
+		}
+		rules, err = analysis.ReadRules(f)
+		f.Close()
+		if err != nil {
+			return err
+		}
+	}
+
+	a, err := analysis.NewAnalyzer(rules)
+	if err != nil {
+		return err
+	}
+
+	results := []filterAnalysis{}
+	for _, src := range sources {
+		var rs []filterAnalysis
+		if fi, err := os.Stat(src); err == nil && fi.IsDir() {
+			rs, err = analyzeDump(a, src)
+			if err != nil {
+				return err
+			}
+		} else {
+			rs, err = analyzeTarget(a, strings.TrimSuffix(src, "/"))
+			if err != nil {
+				return fmt.Errorf("%v: %w", src, err)
+			}
+		}
+		results = append(results, rs...)
+	}
+
+	for _, r := range results {
+		if len(r.Findings) > 0 {
+			foundVulnerable = true
+		}
+		if r.Error != "" {
+			scanFailed = true
+		}
+	}
+
+	out, err := openOutput()
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if outputFormat == "json" {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(results); err != nil {
+			return err
+		}
+	} else if err := writeAnalysis(out, results); err != nil {
+		return err
+	}
+
+	return out.Close()
+}
+
+// analyzeTarget analyzes the filters deployed in the target.
+func analyzeTarget(a *analysis.Analyzer, target string) ([]filterAnalysis, error) {
+	ctx, cancel := interruptContext()
+	defer cancel()
+
+	sources, err := newScanner().FilterSourcesContext(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []filterAnalysis
+	for _, s := range sources {
+		r := filterAnalysis{
+			Source:   target,
+			ID:       s.Filter.ID,
+			Revision: s.Revision,
+		}
+		if s.Err != nil {
+			r.Error = s.Err.Error()
+		} else {
+			r.Findings = a.Analyze(s.Source)
+		}
+		results = append(results, r)
+	}
+
+	return results, nil
+}
+
+// analyzeDump analyzes the filters written to dir by the dump-filters
+// command, checking their hashes against the manifest.
+func analyzeDump(a *analysis.Analyzer, dir string) ([]filterAnalysis, error) {
+	b, err := ioutil.ReadFile(filepath.Join(dir, gozuul.ManifestFile))
+	if err != nil {
+		return nil, err
+	}
+
+	var m gozuul.Manifest
+	if err := json.Unmarshal(b, &m); err != nil {
+		return nil, fmt.Errorf("unable to read manifest of %v: %w", dir, err)
+	}
+
+	var results []filterAnalysis
+	for _, e := range m.Filters {
+		if e.Error != "" {
+			results = append(results, filterAnalysis{Source: dir, ID: e.ID, Revision: e.Revision, Error: e.Error})
+			continue
+		}
+
+		path := filepath.Join(dir, e.File)
+		src, err := ioutil.ReadFile(path)
+		if err != nil {
+			return nil, err
+		}
+		if sum := sha256.Sum256(src); hex.EncodeToString(sum[:]) != e.SHA256 {
+			return nil, fmt.Errorf("hash of %v does not match the manifest", path)
+		}
+
+		results = append(results, filterAnalysis{
+			Source:   dir,
+			ID:       e.ID,
+			Revision: e.Revision,
+			File:     e.File,
+			Findings: a.Analyze(src),
+		})
+	}
+
+	return results, nil
+}
+
+// writeAnalysis writes the flagged filters in a human readable format.
+func writeAnalysis(out io.Writer, results []filterAnalysis) error {
+	flagged, failed := 0, 0
+	for _, r := range results {
+		if r.Error != "" {
+			failed++
+			if _, err := fmt.Fprintf(out, "%v: %v revision %v: error: %v\n", r.Source, r.ID, r.Revision, r.Error); err != nil {
+				return err
+			}
+			continue
+		}
+		if len(r.Findings) == 0 {
+			continue
+		}
+		flagged++
+
+		if _, err := fmt.Fprintf(out, "%v: %v revision %v\n", r.Source, r.ID, r.Revision); err != nil {
+			return err
+		}
+		for _, f := range r.Findings {
+			if _, err := fmt.Fprintf(out, "  %v: [%v] %v: %v\n    %v\n", f.Line, f.Severity, f.Rule, f.Description, f.Text); err != nil {
+				return err
+			}
+		}
+	}
+
+	_, err := fmt.Fprintf(out, "%v filter revisions analyzed, %v flagged, %v not downloaded\n", len(results)-failed, flagged, failed)
+	return err
+}
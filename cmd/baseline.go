@@ -0,0 +1,289 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"sort"
+	"sync"
+	"time"
+
+	gozuul "github.com/adevinta/gozuul"
+	"github.com/adevinta/gozuul/targets"
+
+	"github.com/spf13/cobra"
+)
+
+var baselinePath string
+
+// baselineCmd represents the baseline command
+var baselineCmd = &cobra.Command{
+	Use:   "baseline",
+	Short: "Records and checks known-good filter inventories",
+	Long: `Records the filters deployed in the specified targets, with their latest
+revisions, and later compares the live filters against them, so unauthorized
+uploads through the script manager are spotted. Only GET requests are made to
+the targets.`,
+}
+
+// baselineSaveCmd represents the baseline save command
+var baselineSaveCmd = &cobra.Command{
+	Use:   "save <target>...",
+	Short: "Records the filters deployed in the specified targets",
+	Long: `Records the filters deployed in the specified targets, with their latest
+revisions, in the baseline file. The baselines of other targets already in the
+file are kept.
+
+The targets are specified as in the passive command.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 1 {
+			return fmt.Errorf("incorrect number of args, want 1 at least, got %v", len(args))
+		}
+
+		return saveBaseline(args...)
+	},
+}
+
+// baselineCheckCmd represents the baseline check command
+var baselineCheckCmd = &cobra.Command{
+	Use:   "check [target]...",
+	Short: "Compares the filters deployed in the specified targets against their baseline",
+	Long: `Compares the filters deployed in the specified targets against the ones
+recorded in the baseline file, reporting the added filters, the removed ones
+and the ones whose latest revision changed. Every target in the baseline file
+is checked if none is specified.
+
+The results can be written as text, json or jsonl. With --exit-code, gozuul
+exits with 2 if any target drifted from its baseline, or with 3 if any check
+fails.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		return checkBaseline(args...)
+	},
+}
+
+func init() {
+	baselineCmd.PersistentFlags().StringVar(&baselinePath, "baseline", "gozuul-baseline.json", "file the baselines are stored in")
+	addTargetFlags(baselineSaveCmd)
+	addTargetFlags(baselineCheckCmd)
+	baselineCmd.AddCommand(baselineSaveCmd)
+	baselineCmd.AddCommand(baselineCheckCmd)
+	RootCmd.AddCommand(baselineCmd)
+}
+
+// baselineFile is the file the baselines are stored in.
+type baselineFile struct {
+	Targets map[string]targetBaseline `json:"targets"`
+}
+
+// targetBaseline is the known-good filter inventory of a target.
+type targetBaseline struct {
+	Saved   time.Time        `json:"saved"`
+	Filters gozuul.Inventory `json:"filters"`
+}
+
+// readBaselines reads the baseline file. A missing file is read as an empty
+// one if allowMissing is set.
+func readBaselines(allowMissing bool) (baselineFile, error) {
+	bf := baselineFile{Targets: make(map[string]targetBaseline)}
+
+	b, err := ioutil.ReadFile(baselinePath)
+	if os.IsNotExist(err) && allowMissing {
+		return bf, nil
+	}
+	if err != nil {
+		return bf, err
+	}
+
+	if err := json.Unmarshal(b, &bf); err != nil {
+		return bf, fmt.Errorf("unable to read baseline file %v: %w", baselinePath, err)
+	}
+	if bf.Targets == nil {
+		bf.Targets = make(map[string]targetBaseline)
+	}
+
+	return bf, nil
+}
+
+// liveInventories calls f with the live inventory of every target, or with
+// the error that prevented getting it. The unreachable targets are reported
+// with the error of their probes.
+func liveInventories(targets []string, unreachable []targets.Unreachable, f func(target string, inv gozuul.Inventory, err error)) {
+	scanner := newScanner()
+
+	for _, u := range unreachable {
+		f(u.URL, nil, probeError(u))
+	}
+
+	var mu sync.Mutex
+	forEachTarget(targets, 30, func(ctx context.Context, target string) {
+		filters, err := scanner.ListFiltersContext(ctx, target)
+
+		mu.Lock()
+		defer mu.Unlock()
+
+		f(target, gozuul.NewInventory(filters), err)
+	})
+}
+
+func saveBaseline(specs ...string) error {
+	targets, unreachable, err := resolveTargets(specs, 30)
+	if err != nil {
+		return err
+	}
+
+	bf, err := readBaselines(true)
+	if err != nil {
+		return err
+	}
+
+	saved := 0
+	liveInventories(targets, unreachable, func(target string, inv gozuul.Inventory, err error) {
+		if err != nil {
+			scanFailed = true
+			fmt.Fprintf(os.Stderr, "%v: error: %v\n", target, err)
+			return
+		}
+
+		bf.Targets[target] = targetBaseline{Saved: time.Now().UTC(), Filters: inv}
+		saved++
+	})
+
+	b, err := json.MarshalIndent(bf, "", "  ")
+	if err != nil {
+		return err
+	}
+	if err := ioutil.WriteFile(baselinePath, append(b, '\n'), 0644); err != nil {
+		return err
+	}
+
+	fmt.Printf("%v of %v target baselines saved to %v\n", saved, len(targets)+len(unreachable), baselinePath)
+
+	return nil
+}
+
+// driftResult is the result of checking a target against its baseline.
+type driftResult struct {
+	Target string `json:"target"`
+	gozuul.Drift
+	Error string `json:"error,omitempty"`
+}
+
+func checkBaseline(specs ...string) error {
+	if outputFormat != "text" && outputFormat != "json" && outputFormat != "jsonl" {
+		return fmt.Errorf("unsupported output format for baseline check: %v", outputFormat)
+	}
+
+	bf, err := readBaselines(false)
+	if err != nil {
+		return err
+	}
+
+	var (
+		unreachable []targets.Unreachable
+		targets     []string
+	)
+	if len(specs) == 0 {
+		for target := range bf.Targets {
+			targets = append(targets, target)
+		}
+		sort.Strings(targets)
+	} else if targets, unreachable, err = resolveTargets(specs, 30); err != nil {
+		return err
+	}
+
+	out, err := openOutput()
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	var (
+		results = []driftResult{}
+		werr    error
+	)
+	liveInventories(targets, unreachable, func(target string, inv gozuul.Inventory, err error) {
+		r := driftResult{Target: target}
+
+		base, ok := bf.Targets[target]
+		switch {
+		case err != nil:
+			r.Error = err.Error()
+		case !ok:
+			r.Error = fmt.Sprintf("no baseline in %v", baselinePath)
+		default:
+			r.Drift = gozuul.CompareInventories(base.Filters, inv)
+		}
+
+		if r.Error != "" {
+			scanFailed = true
+		} else if !r.Empty() {
+			foundVulnerable = true
+		}
+
+		if outputFormat == "json" {
+			results = append(results, r)
+			return
+		}
+		if werr == nil {
+			werr = writeDrift(out, r)
+		}
+	})
+	if werr != nil {
+		return werr
+	}
+
+	if outputFormat == "json" {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(results); err != nil {
+			return err
+		}
+	}
+
+	return out.Close()
+}
+
+// writeDrift writes a drift result in the text or jsonl formats.
+func writeDrift(out io.Writer, r driftResult) error {
+	if outputFormat == "jsonl" {
+		return json.NewEncoder(out).Encode(r)
+	}
+
+	if r.Error != "" {
+		_, err := fmt.Fprintf(out, "%v: error: %v\n", r.Target, r.Error)
+		return err
+	}
+
+	if r.Empty() {
+		_, err := fmt.Fprintf(out, "%v: no drift\n", r.Target)
+		return err
+	}
+
+	if _, err := fmt.Fprintf(out, "%v: %v added, %v removed, %v bumped\n", r.Target, len(r.Added), len(r.Removed), len(r.Bumped)); err != nil {
+		return err
+	}
+	for _, e := range r.Added {
+		if _, err := fmt.Fprintf(out, "  added %v revision %v\n", e.ID, e.Revision); err != nil {
+			return err
+		}
+	}
+	for _, e := range r.Removed {
+		if _, err := fmt.Fprintf(out, "  removed %v revision %v\n", e.ID, e.Revision); err != nil {
+			return err
+		}
+	}
+	for _, b := range r.Bumped {
+		if _, err := fmt.Fprintf(out, "  bumped %v revision %v -> %v\n", b.ID, b.Baseline, b.Live); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
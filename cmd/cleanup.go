@@ -0,0 +1,177 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	gozuul "github.com/adevinta/gozuul"
+
+	"github.com/spf13/cobra"
+)
+
+// cleanupCmd represents the cleanup command
+var cleanupCmd = &cobra.Command{
+	Use:   "cleanup <target>...",
+	Short: "Deactivates the Vulncheck filters left in the specified targets",
+	Long: `Deactivates the Vulncheck filters left in the specified targets by previous
+active scans. For every target, it checks whether the filter serves the check
+endpoint, deactivates every revision of the filter listed by the filter
+loader, and verifies that the filter stopped serving the check endpoint,
+waiting for it up to 63 seconds. The filter may keep serving it until the
+target is restarted, in which case the status of the target is still_enabled.
+If the filter stopped serving it but some revisions could not be deactivated,
+the status is incomplete.
+
+The cleanup modifies the targets, so it must be explicitly authorized with the
+--authorized flag.
+
+The targets are specified as in the passive command. The results can be
+written as text, json or jsonl. With --exit-code, gozuul exits with 2 if the
+filter is still enabled in any target, or with 3 if any cleanup fails or is
+incomplete.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 1 {
+			return fmt.Errorf("incorrect number of args, want 1 at least, got %v", len(args))
+		}
+
+		return cleanup(args...)
+	},
+}
+
+func init() {
+	cleanupCmd.Flags().BoolVar(&authorized, "authorized", false, "confirms that you are authorized to modify the targets")
+	cleanupCmd.Flags().IntVarP(&concurrency, "concurrency", "c", 10, "maximum number of targets cleaned up at the same time")
+	addTargetFlags(cleanupCmd)
+	RootCmd.AddCommand(cleanupCmd)
+}
+
+// cleanupResult is the result of the cleanup of a target.
+type cleanupResult struct {
+	Target string `json:"target"`
+	gozuul.CleanupResult
+	Error string `json:"error,omitempty"`
+}
+
+func cleanup(specs ...string) error {
+	if !authorized {
+		return errors.New("cleanups modify the targets, confirm that you are authorized to modify them with --authorized")
+	}
+	if concurrency < 1 {
+		return fmt.Errorf("concurrency must be greater than 0, got %v", concurrency)
+	}
+	if outputFormat != "text" && outputFormat != "json" && outputFormat != "jsonl" {
+		return fmt.Errorf("unsupported output format for cleanups: %v", outputFormat)
+	}
+
+	targets, unreachable, err := resolveTargets(specs, concurrency)
+	if err != nil {
+		return err
+	}
+
+	out, err := openOutput()
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	scanner := newScanner()
+
+	var (
+		mu      sync.Mutex
+		results = []cleanupResult{}
+		werr    error
+	)
+	report := func(r cleanupResult) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		switch {
+		case r.Error != "", r.Status == gozuul.CleanupIncomplete:
+			scanFailed = true
+		case r.Status == gozuul.CleanupStillEnabled:
+			foundVulnerable = true
+		}
+
+		if outputFormat == "json" {
+			results = append(results, r)
+			return
+		}
+		if werr == nil {
+			werr = writeCleanup(out, r)
+		}
+	}
+	for _, u := range unreachable {
+		report(cleanupResult{Target: u.URL, Error: probeError(u).Error()})
+	}
+	forEachTarget(targets, concurrency, func(ctx context.Context, target string) {
+		cr, err := scanner.CleanupContext(ctx, target)
+
+		r := cleanupResult{Target: target, CleanupResult: cr}
+		if err != nil {
+			r.Error = err.Error()
+		}
+		report(r)
+	})
+	if werr != nil {
+		return werr
+	}
+
+	if outputFormat == "json" {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(results); err != nil {
+			return err
+		}
+	}
+
+	return out.Close()
+}
+
+// writeCleanup writes a cleanup result in the text or jsonl formats.
+func writeCleanup(out io.Writer, r cleanupResult) error {
+	if outputFormat == "jsonl" {
+		return json.NewEncoder(out).Encode(r)
+	}
+
+	if r.Error != "" {
+		_, err := fmt.Fprintf(out, "%v: error: %v\n", r.Target, r.Error)
+		return err
+	}
+
+	if r.Status == gozuul.CleanupNothingFound {
+		_, err := fmt.Fprintf(out, "%v: %v\n", r.Target, r.Status)
+		return err
+	}
+
+	if _, err := fmt.Fprintf(out, "%v: %v (revisions: %v; deactivated: %v)\n", r.Target, r.Status, joinInts(r.Revisions), joinInts(r.Deactivated)); err != nil {
+		return err
+	}
+	for _, f := range r.Failures {
+		if _, err := fmt.Fprintf(out, "  revision %v not deactivated: %v\n", f.Revision, f.Error); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+func joinInts(ns []int) string {
+	if len(ns) == 0 {
+		return "none"
+	}
+
+	s := make([]string, len(ns))
+	for i, n := range ns {
+		s[i] = fmt.Sprint(n)
+	}
+	return strings.Join(s, ", ")
+}
@@ -0,0 +1,55 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+
+	gozuul "github.com/adevinta/gozuul"
+)
+
+var configFile string
+
+// config is the JSON configuration file of the CLI, for instance:
+//
+//	{
+//	  "endpoints": {
+//	    "filter_loader": "/ops/filterLoader.jsp",
+//	    "script_manager": "/ops/scriptmanager",
+//	    "upload": "/ops/scriptmanager?action=UPLOAD",
+//	    "check": "/vulncheck-spt"
+//	  }
+//	}
+//
+// The endpoints not set keep their default values.
+type config struct {
+	Endpoints *gozuul.Endpoints `json:"endpoints"`
+}
+
+// cfg is the configuration read from the file given with --config.
+var cfg config
+
+// loadConfig reads the configuration file given with --config, if any.
+func loadConfig() error {
+	if configFile == "" {
+		return nil
+	}
+
+	f, err := os.Open(configFile)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	dec := json.NewDecoder(f)
+	dec.DisallowUnknownFields()
+	if err := dec.Decode(&cfg); err != nil {
+		return fmt.Errorf("unable to read config file %v: %w", configFile, err)
+	}
+
+	return nil
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"net/http"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/adevinta/gozuul/discovery"
+
+	"github.com/spf13/cobra"
+)
+
+const registryTimeout = 30 * time.Second
+
+var (
+	eurekaApps        []string
+	eurekaAllStatuses bool
+	clusterDomain     string
+	discoverList      bool
+)
+
+// discoverCmd represents the discover command
+var discoverCmd = &cobra.Command{
+	Use:   "discover",
+	Short: "Discovers Zuul instances and executes a passive scan against them",
+}
+
+// discoverEurekaCmd represents the discover eureka command
+var discoverEurekaCmd = &cobra.Command{
+	Use:   "eureka <registry-url>",
+	Short: "Discovers the Zuul instances registered in Eureka",
+	Long: `Discovers the Zuul instances registered in a Eureka server and executes a
+new passive scan against them.
+
+The instances whose app name or metadata contain "zuul", or whose app is
+given with --app, are scanned in their secure port, or in their plain port if
+the secure one is not enabled. Only the instances whose status is UP are
+scanned, unless --all-statuses is given.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return fmt.Errorf("incorrect number of args, want 1, got %v", len(args))
+		}
+
+		ctx, cancel := interruptContext()
+		defer cancel()
+
+		client := &http.Client{Timeout: registryTimeout}
+		urls, err := discovery.EurekaTargets(ctx, client, args[0], eurekaMatch, eurekaAllStatuses)
+		if err != nil {
+			return err
+		}
+
+		return discovered(urls)
+	},
+}
+
+// discoverKubernetesCmd represents the discover kubernetes command
+var discoverKubernetesCmd = &cobra.Command{
+	Use:   "kubernetes <manifests-dir>",
+	Short: "Discovers the Zuul instances described in Kubernetes manifests",
+	Long: `Discovers the Zuul instances described in the Kubernetes manifests of a
+directory and executes a new passive scan against them.
+
+The workloads whose images or labels contain "zuul" are the Zuul instances.
+The Services that select their pods are scanned by their in-cluster names,
+as well as the hosts of the Ingresses that route to those Services.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return fmt.Errorf("incorrect number of args, want 1, got %v", len(args))
+		}
+
+		urls, skipped, err := discovery.KubernetesTargets(args[0], clusterDomain, discovery.IsZuulWorkload)
+		if err != nil {
+			return err
+		}
+		for _, e := range skipped {
+			fmt.Fprintf(os.Stderr, "warning: %v\n", e)
+		}
+
+		return discovered(urls)
+	},
+}
+
+func init() {
+	discoverEurekaCmd.Flags().StringSliceVar(&eurekaApps, "app", nil, "names of the Eureka apps to scan besides the ones detected as Zuul")
+	discoverEurekaCmd.Flags().BoolVar(&eurekaAllStatuses, "all-statuses", false, "scans the instances whatever their status is, not only the UP ones")
+	discoverKubernetesCmd.Flags().StringVar(&clusterDomain, "cluster-domain", discovery.DefaultClusterDomain, "domain of the cluster used to build the in-cluster URLs")
+
+	discoverCmd.PersistentFlags().BoolVar(&discoverList, "list", false, "prints the discovered targets instead of scanning them")
+	discoverCmd.AddCommand(discoverEurekaCmd)
+	discoverCmd.AddCommand(discoverKubernetesCmd)
+	RootCmd.AddCommand(discoverCmd)
+}
+
+// eurekaMatch reports whether the instance is a Zuul instance or belongs to
+// one of the apps given with --app.
+func eurekaMatch(i discovery.Instance) bool {
+	for _, app := range eurekaApps {
+		if strings.EqualFold(app, i.App) {
+			return true
+		}
+	}
+
+	return discovery.IsZuul(i)
+}
+
+// discovered prints or scans the discovered targets.
+func discovered(urls []string) error {
+	if discoverList {
+		for _, u := range urls {
+			fmt.Println(u)
+		}
+		return nil
+	}
+
+	if len(urls) == 0 {
+		if verbose {
+			fmt.Println("no Zuul instances discovered")
+		}
+		return nil
+	}
+
+	return passiveScan(urls...)
+}
@@ -0,0 +1,64 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package cmd
+
+import (
+	"fmt"
+	"os"
+	"path/filepath"
+	"strings"
+
+	gozuul "github.com/adevinta/gozuul"
+
+	"github.com/spf13/cobra"
+)
+
+// dumpFiltersCmd represents the dump-filters command
+var dumpFiltersCmd = &cobra.Command{
+	Use:   "dump-filters <target> <dir>",
+	Short: "Downloads the source of the filters deployed in the specified target",
+	Long: `Downloads the source of every revision of the filters listed by the filter
+loader of the specified target, following the DOWNLOAD links of the filter
+loader page, and writes them to the specified directory along with a
+manifest, ` + gozuul.ManifestFile + `, containing the ID, the revision and the
+SHA-256 hash of every filter. The revisions that can not be downloaded are
+recorded in the manifest with their error, and gozuul exits with 3 if
+--exit-code is set. Only GET requests are made to the target.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 2 {
+			return fmt.Errorf("incorrect number of args, want 2, got %v", len(args))
+		}
+
+		return dumpFilters(args[0], args[1])
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(dumpFiltersCmd)
+}
+
+func dumpFilters(target, dir string) error {
+	ctx, cancel := interruptContext()
+	defer cancel()
+
+	m, err := newScanner().DumpFiltersContext(ctx, strings.TrimSuffix(target, "/"), dir)
+	if err != nil {
+		return err
+	}
+
+	written := 0
+	for _, e := range m.Filters {
+		if e.Error != "" {
+			scanFailed = true
+			fmt.Fprintf(os.Stderr, "%v revision %v: error: %v\n", e.ID, e.Revision, e.Error)
+			continue
+		}
+		written++
+	}
+
+	fmt.Printf("%v of %v filter revisions written to %v\n", written, len(m.Filters), filepath.Join(dir, gozuul.ManifestFile))
+
+	return nil
+}
@@ -0,0 +1,92 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"text/tabwriter"
+
+	gozuul "github.com/adevinta/gozuul"
+
+	"github.com/spf13/cobra"
+)
+
+// filtersCmd represents the filters command
+var filtersCmd = &cobra.Command{
+	Use:   "filters <target>",
+	Short: "Lists the filters deployed in the specified target",
+	Long: `Lists the filters known by the filter loader of the specified target, with
+all their revisions. Only GET requests are made to the target.
+
+The filters are printed as a table, or as JSON with -o json.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) != 1 {
+			return fmt.Errorf("incorrect number of args, want 1, got %v", len(args))
+		}
+
+		return listFilters(args[0])
+	},
+}
+
+func init() {
+	RootCmd.AddCommand(filtersCmd)
+}
+
+func listFilters(target string) error {
+	if outputFormat != "text" && outputFormat != "json" {
+		return fmt.Errorf("unsupported output format for filters: %v", outputFormat)
+	}
+
+	ctx, cancel := interruptContext()
+	defer cancel()
+
+	filters, err := newScanner().ListFiltersContext(ctx, strings.TrimSuffix(target, "/"))
+	if err != nil {
+		return err
+	}
+
+	out, err := openOutput()
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	if outputFormat == "json" {
+		if filters == nil {
+			filters = []gozuul.Filter{}
+		}
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(filters); err != nil {
+			return err
+		}
+		return out.Close()
+	}
+
+	if err := writeFilters(out, filters); err != nil {
+		return err
+	}
+
+	return out.Close()
+}
+
+// writeFilters writes the filters as a table, one row per revision.
+func writeFilters(out io.Writer, filters []gozuul.Filter) error {
+	w := tabwriter.NewWriter(out, 0, 0, 2, ' ', 0)
+	fmt.Fprintln(w, "ID\tNAME\tTYPE\tREVISION\tACTIVE\tCANARY\tORDER\tCREATED")
+	for _, f := range filters {
+		for _, r := range f.Revisions {
+			order := ""
+			if r.Order != 0 {
+				order = fmt.Sprint(r.Order)
+			}
+			fmt.Fprintf(w, "%v\t%v\t%v\t%v\t%v\t%v\t%v\t%v\n", f.ID, f.Name, f.Type, r.Revision, r.Active, r.Canary, order, r.Created)
+		}
+	}
+	return w.Flush()
+}
@@ -0,0 +1,130 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package cmd
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"strings"
+	"sync"
+
+	gozuul "github.com/adevinta/gozuul"
+
+	"github.com/spf13/cobra"
+)
+
+// fingerprintCmd represents the fingerprint command
+var fingerprintCmd = &cobra.Command{
+	Use:   "fingerprint <target>...",
+	Short: "Identifies the kind of Zuul running in the specified targets",
+	Long: `Identifies whether the specified targets run Zuul 1, Zuul 2 or Spring Cloud
+Netflix Zuul, and the persistence backend of their filters. Only GET requests
+are made to the targets.
+
+The targets are specified as in the passive command. The results can be
+written as text, json or jsonl.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 1 {
+			return fmt.Errorf("incorrect number of args, want 1 at least, got %v", len(args))
+		}
+
+		return fingerprint(args...)
+	},
+}
+
+func init() {
+	addTargetFlags(fingerprintCmd)
+	RootCmd.AddCommand(fingerprintCmd)
+}
+
+// fingerprintResult is the fingerprint of a target.
+type fingerprintResult struct {
+	Target string `json:"target"`
+	gozuul.FingerprintResult
+	Error string `json:"error,omitempty"`
+}
+
+func fingerprint(specs ...string) error {
+	if outputFormat != "text" && outputFormat != "json" && outputFormat != "jsonl" {
+		return fmt.Errorf("unsupported output format for fingerprints: %v", outputFormat)
+	}
+
+	targets, unreachable, err := resolveTargets(specs, 30)
+	if err != nil {
+		return err
+	}
+
+	out, err := openOutput()
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	scanner := newScanner()
+
+	var (
+		mu      sync.Mutex
+		results = []fingerprintResult{}
+		werr    error
+	)
+	report := func(r fingerprintResult) {
+		mu.Lock()
+		defer mu.Unlock()
+
+		if outputFormat == "json" {
+			results = append(results, r)
+			return
+		}
+		if werr == nil {
+			werr = writeFingerprint(out, r)
+		}
+	}
+	for _, u := range unreachable {
+		report(fingerprintResult{Target: u.URL, Error: probeError(u).Error()})
+	}
+	forEachTarget(targets, 30, func(ctx context.Context, target string) {
+		fp, err := scanner.FingerprintContext(ctx, target)
+
+		r := fingerprintResult{Target: target, FingerprintResult: fp}
+		if err != nil {
+			r.Error = err.Error()
+		}
+		report(r)
+	})
+	if werr != nil {
+		return werr
+	}
+
+	if outputFormat == "json" {
+		enc := json.NewEncoder(out)
+		enc.SetIndent("", "  ")
+		if err := enc.Encode(results); err != nil {
+			return err
+		}
+	}
+
+	return out.Close()
+}
+
+// writeFingerprint writes a fingerprint in the text or jsonl formats.
+func writeFingerprint(out io.Writer, r fingerprintResult) error {
+	if outputFormat == "jsonl" {
+		return json.NewEncoder(out).Encode(r)
+	}
+
+	if r.Error != "" {
+		_, err := fmt.Fprintf(out, "%v: error: %v\n", r.Target, r.Error)
+		return err
+	}
+
+	_, err := fmt.Fprintf(out, "%v: %v (backend: %v)\n", r.Target, r.Flavor, r.Backend)
+	if err == nil && verbose && len(r.Hints) > 0 {
+		_, err = fmt.Fprintf(out, "  %v\n", strings.Join(r.Hints, "\n  "))
+	}
+
+	return err
+}
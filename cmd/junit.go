@@ -0,0 +1,121 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package cmd
+
+import (
+	"encoding/xml"
+	"fmt"
+	"io"
+
+	gozuul "github.com/adevinta/gozuul"
+)
+
+type junitTestSuites struct {
+	XMLName xml.Name         `xml:"testsuites"`
+	Suites  []junitTestSuite `xml:"testsuite"`
+}
+
+type junitTestSuite struct {
+	Name      string          `xml:"name,attr"`
+	Tests     int             `xml:"tests,attr"`
+	Failures  int             `xml:"failures,attr"`
+	Errors    int             `xml:"errors,attr"`
+	Skipped   int             `xml:"skipped,attr"`
+	Time      string          `xml:"time,attr"`
+	TestCases []junitTestCase `xml:"testcase"`
+}
+
+type junitTestCase struct {
+	ClassName string        `xml:"classname,attr"`
+	Name      string        `xml:"name,attr"`
+	Time      string        `xml:"time,attr"`
+	Failure   *junitMessage `xml:"failure,omitempty"`
+	Error     *junitMessage `xml:"error,omitempty"`
+	Skipped   *junitMessage `xml:"skipped,omitempty"`
+}
+
+type junitMessage struct {
+	Message string `xml:"message,attr"`
+	Type    string `xml:"type,attr,omitempty"`
+	Text    string `xml:",chardata"`
+}
+
+// isFinding reports whether the result is reported as a finding, that is, as
+// a failure of the scan.
+func isFinding(r scanResult) bool {
+	return r.Verdict == gozuul.VerdictVulnerable || r.Verdict == gozuul.VerdictPossiblyVulnerable
+}
+
+// junitWriter writes a JUnit XML report when closed. Every target is a test
+// case that fails when the target is vulnerable, errors when the scan failed,
+// and is skipped when the admin endpoints are disabled.
+type junitWriter struct {
+	out   io.WriteCloser
+	suite junitTestSuite
+	time  float64
+}
+
+func newJUnitWriter(out io.WriteCloser) *junitWriter {
+	return &junitWriter{
+		out:   out,
+		suite: junitTestSuite{Name: "gozuul"},
+	}
+}
+
+func (w *junitWriter) Write(r scanResult) error {
+	tc := junitTestCase{
+		ClassName: "gozuul." + nflx2016003Rule.ID,
+		Name:      r.Target,
+		Time:      fmt.Sprintf("%.3f", r.Duration/1000),
+	}
+
+	switch {
+	case isFinding(r):
+		tc.Failure = &junitMessage{
+			Message: fmt.Sprintf("%v is %v", r.Target, r.Verdict),
+			Type:    r.Verdict.String(),
+			Text:    r.Reason,
+		}
+		w.suite.Failures++
+	case r.Error != "":
+		tc.Error = &junitMessage{
+			Message: r.Error,
+			Type:    r.Verdict.String(),
+			Text:    r.Reason,
+		}
+		w.suite.Errors++
+	case r.AdminDisabled:
+		tc.Skipped = &junitMessage{Message: r.Reason}
+		w.suite.Skipped++
+	}
+
+	w.suite.Tests++
+	w.suite.TestCases = append(w.suite.TestCases, tc)
+	w.time += r.Duration / 1000
+
+	return nil
+}
+
+func (w *junitWriter) Close() error {
+	w.suite.Time = fmt.Sprintf("%.3f", w.time)
+
+	if _, err := io.WriteString(w.out, xml.Header); err != nil {
+		w.out.Close()
+		return err
+	}
+
+	enc := xml.NewEncoder(w.out)
+	enc.Indent("", "  ")
+	if err := enc.Encode(junitTestSuites{Suites: []junitTestSuite{w.suite}}); err != nil {
+		w.out.Close()
+		return err
+	}
+	if _, err := io.WriteString(w.out, "\n"); err != nil {
+		w.out.Close()
+		return err
+	}
+
+	return w.out.Close()
+}
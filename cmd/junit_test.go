@@ -0,0 +1,118 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/xml"
+	"strings"
+	"testing"
+
+	gozuul "github.com/adevinta/gozuul"
+)
+
+func TestJUnitWriter(t *testing.T) {
+	var b bytes.Buffer
+	w := newJUnitWriter(nopCloser{&b})
+	for _, r := range testResults() {
+		if err := w.Write(r); err != nil {
+			t.Fatalf("nil error expected, got %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("nil error expected, got %v", err)
+	}
+
+	if !strings.HasPrefix(b.String(), xml.Header) {
+		t.Errorf("XML header expected, got: %q", b.String())
+	}
+
+	var suites junitTestSuites
+	if err := xml.Unmarshal(b.Bytes(), &suites); err != nil {
+		t.Fatalf("valid XML expected, got %v", err)
+	}
+	if len(suites.Suites) != 1 {
+		t.Fatalf("test suites expected: 1, got: %v", len(suites.Suites))
+	}
+
+	s := suites.Suites[0]
+	if s.Tests != 5 || s.Failures != 2 || s.Errors != 1 || s.Skipped != 1 {
+		t.Errorf("tests, failures, errors and skipped expected: 5, 2, 1, 1, got: %v, %v, %v, %v", s.Tests, s.Failures, s.Errors, s.Skipped)
+	}
+	if s.Time != "2.000" {
+		t.Errorf("time expected: 2.000, got: %v", s.Time)
+	}
+
+	outcomes := map[string]string{}
+	for _, tc := range s.TestCases {
+		switch {
+		case tc.Failure != nil:
+			outcomes[tc.Name] = "failure"
+		case tc.Error != nil:
+			outcomes[tc.Name] = "error"
+		case tc.Skipped != nil:
+			outcomes[tc.Name] = "skipped"
+		default:
+			outcomes[tc.Name] = "passed"
+		}
+	}
+	want := map[string]string{
+		"http://vulnerable.example.com":  "failure",
+		"http://possibly.example.com":    "failure",
+		"http://disabled.example.com":    "skipped",
+		"http://safe.example.com":        "passed",
+		"http://unreachable.example.com": "error",
+	}
+	for name, o := range want {
+		if outcomes[name] != o {
+			t.Errorf("%v: outcome expected: %v, got: %v", name, o, outcomes[name])
+		}
+	}
+}
+
+func TestExitStatus(t *testing.T) {
+	results := testResults()
+	vulnerable, possibly, disabled, safe, unreachable := results[0], results[1], results[2], results[3], results[4]
+
+	inconclusive := safe
+	inconclusive.Verdict = gozuul.VerdictInconclusive
+
+	tests := []struct {
+		name    string
+		results []scanResult
+		want    int
+	}{
+		{name: "none", want: 0},
+		{name: "notVulnerable", results: []scanResult{safe, disabled, inconclusive}, want: 0},
+		{name: "vulnerable", results: []scanResult{safe, vulnerable}, want: exitVulnerable},
+		{name: "possiblyVulnerable", results: []scanResult{possibly}, want: exitVulnerable},
+		{name: "scanErrors", results: []scanResult{safe, unreachable}, want: exitScanErrors},
+		{name: "vulnerableAndScanErrors", results: []scanResult{unreachable, vulnerable}, want: exitVulnerable},
+	}
+
+	defer func() {
+		foundVulnerable, scanFailed = false, false
+	}()
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			foundVulnerable, scanFailed = false, false
+
+			ch := make(chan scanResult, len(tt.results))
+			for _, r := range tt.results {
+				ch <- r
+			}
+			close(ch)
+
+			var b bytes.Buffer
+			if err := writeResults(newJUnitWriter(nopCloser{&b}), ch); err != nil {
+				t.Fatalf("nil error expected, got %v", err)
+			}
+			if got := exitStatus(); got != tt.want {
+				t.Errorf("exit status expected: %v, got: %v", tt.want, got)
+			}
+		})
+	}
+}
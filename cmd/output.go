@@ -0,0 +1,206 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package cmd
+
+import (
+	"encoding/csv"
+	"encoding/json"
+	"fmt"
+	"io"
+	"os"
+	"strconv"
+	"time"
+)
+
+// resultWriter writes the results of the scans in a given format.
+type resultWriter interface {
+	Write(r scanResult) error
+	// Close flushes the pending results, if any.
+	Close() error
+}
+
+// newResultWriter returns the resultWriter for the format and output file
+// configured by the flags. When verdicts is true, the text format prints the
+// verdict of every target instead of only the vulnerable ones.
+func newResultWriter(verdicts bool) (resultWriter, error) {
+	out, err := openOutput()
+	if err != nil {
+		return nil, err
+	}
+
+	switch outputFormat {
+	case "text":
+		return &textWriter{out: out, verdicts: verdicts}, nil
+	case "json":
+		return &jsonWriter{out: out, results: []scanResult{}}, nil
+	case "jsonl":
+		return &jsonlWriter{out: out, enc: json.NewEncoder(out)}, nil
+	case "csv":
+		return newCSVWriter(out), nil
+	case "sarif":
+		return &sarifWriter{out: out, results: []sarifResult{}}, nil
+	case "junit":
+		return newJUnitWriter(out), nil
+	}
+
+	out.Close()
+	return nil, fmt.Errorf("unknown output format: %v", outputFormat)
+}
+
+// writeResults writes all the results received from the channel, and closes
+// the resultWriter. It also records whether any finding or scan error has
+// been written, for the exit code of the command.
+func writeResults(w resultWriter, results <-chan scanResult) error {
+	var err error
+	for r := range results {
+		if isFinding(r) {
+			foundVulnerable = true
+		} else if r.Error != "" {
+			scanFailed = true
+		}
+
+		if err == nil {
+			err = w.Write(r)
+		}
+	}
+
+	if cerr := w.Close(); err == nil {
+		err = cerr
+	}
+
+	return err
+}
+
+// openOutput opens the output file configured by the flags, or returns
+// stdout if there is none.
+func openOutput() (io.WriteCloser, error) {
+	if outputFile == "" {
+		return nopCloser{os.Stdout}, nil
+	}
+
+	return os.Create(outputFile)
+}
+
+type nopCloser struct {
+	io.Writer
+}
+
+func (nopCloser) Close() error {
+	return nil
+}
+
+// textWriter writes the results in a human readable format.
+type textWriter struct {
+	out      io.WriteCloser
+	verdicts bool
+}
+
+func (w *textWriter) Write(r scanResult) error {
+	var err error
+	switch {
+	case w.verdicts:
+		_, err = fmt.Fprintf(w.out, "%v: %v (%v)\n", r.Target, r.Verdict, r.Reason)
+	case r.Error == "" && r.Vulnerable:
+		_, err = fmt.Fprintf(w.out, "%v is vulnerable\n", r.Target)
+	}
+	if err != nil {
+		return err
+	}
+
+	if r.Error != "" && verbose {
+		_, err = fmt.Fprintln(w.out, r.Error)
+	}
+
+	return err
+}
+
+func (w *textWriter) Close() error {
+	return w.out.Close()
+}
+
+// jsonWriter writes all the results as a JSON array when closed.
+type jsonWriter struct {
+	out     io.WriteCloser
+	results []scanResult
+}
+
+func (w *jsonWriter) Write(r scanResult) error {
+	w.results = append(w.results, r)
+	return nil
+}
+
+func (w *jsonWriter) Close() error {
+	enc := json.NewEncoder(w.out)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(w.results); err != nil {
+		w.out.Close()
+		return err
+	}
+
+	return w.out.Close()
+}
+
+// jsonlWriter writes every result as a JSON object in its own line.
+type jsonlWriter struct {
+	out io.WriteCloser
+	enc *json.Encoder
+}
+
+func (w *jsonlWriter) Write(r scanResult) error {
+	return w.enc.Encode(r)
+}
+
+func (w *jsonlWriter) Close() error {
+	return w.out.Close()
+}
+
+// csvWriter writes every result as a CSV record. The evidence is not
+// included.
+type csvWriter struct {
+	out io.WriteCloser
+	w   *csv.Writer
+	err error
+}
+
+var csvHeader = []string{
+	"target", "verdict", "reason", "prefix", "prev_enabled", "admin_disabled",
+	"vulnerable", "might_vulnerable", "error", "start", "duration_ms",
+}
+
+func newCSVWriter(out io.WriteCloser) *csvWriter {
+	w := &csvWriter{out: out, w: csv.NewWriter(out)}
+	w.err = w.w.Write(csvHeader)
+	return w
+}
+
+func (w *csvWriter) Write(r scanResult) error {
+	if w.err != nil {
+		return w.err
+	}
+
+	return w.w.Write([]string{
+		r.Target,
+		r.Verdict.String(),
+		r.Reason,
+		r.Prefix,
+		strconv.FormatBool(r.PrevEnabled),
+		strconv.FormatBool(r.AdminDisabled),
+		strconv.FormatBool(r.Vulnerable),
+		strconv.FormatBool(r.MightVulnerable),
+		r.Error,
+		r.Start.Format(time.RFC3339Nano),
+		strconv.FormatFloat(r.Duration, 'f', 3, 64),
+	})
+}
+
+func (w *csvWriter) Close() error {
+	w.w.Flush()
+	if err := w.w.Error(); err != nil {
+		w.out.Close()
+		return err
+	}
+
+	return w.out.Close()
+}
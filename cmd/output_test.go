@@ -0,0 +1,114 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/csv"
+	"reflect"
+	"testing"
+	"time"
+
+	gozuul "github.com/adevinta/gozuul"
+)
+
+// testResults returns a result for every verdict a scan of the CLI can
+// finish with.
+func testResults() []scanResult {
+	start := time.Date(2019, 3, 4, 10, 0, 0, 0, time.UTC)
+	return []scanResult{
+		{
+			Target:    "http://vulnerable.example.com",
+			ResultSet: gozuul.ResultSet{Verdict: gozuul.VerdictVulnerable, Reason: "vulnerable", Vulnerable: true},
+			Start:     start,
+			Duration:  1500,
+		},
+		{
+			Target:    "http://possibly.example.com",
+			ResultSet: gozuul.ResultSet{Verdict: gozuul.VerdictPossiblyVulnerable, Reason: "possibly", MightVulnerable: true},
+			Start:     start,
+			Duration:  500,
+		},
+		{
+			Target:    "http://disabled.example.com",
+			ResultSet: gozuul.ResultSet{Verdict: gozuul.VerdictAdminDisabled, Reason: "disabled", AdminDisabled: true},
+			Start:     start,
+		},
+		{
+			Target:    "http://safe.example.com",
+			ResultSet: gozuul.ResultSet{Verdict: gozuul.VerdictNotVulnerable, Reason: "safe", Prefix: "/gateway"},
+			Start:     start,
+		},
+		{
+			Target:    "http://unreachable.example.com",
+			ResultSet: gozuul.ResultSet{Verdict: gozuul.VerdictUnreachable, Reason: "unreachable"},
+			Error:     "network failure",
+			Start:     start,
+		},
+	}
+}
+
+func TestCSVWriter(t *testing.T) {
+	var b bytes.Buffer
+	w := newCSVWriter(nopCloser{&b})
+	for _, r := range testResults() {
+		if err := w.Write(r); err != nil {
+			t.Fatalf("nil error expected, got %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("nil error expected, got %v", err)
+	}
+
+	records, err := csv.NewReader(&b).ReadAll()
+	if err != nil {
+		t.Fatalf("valid CSV expected, got %v", err)
+	}
+	if len(records) != len(testResults())+1 {
+		t.Fatalf("records expected: %v, got: %v", len(testResults())+1, len(records))
+	}
+
+	header := []string{
+		"target", "verdict", "reason", "prefix", "prev_enabled", "admin_disabled",
+		"vulnerable", "might_vulnerable", "error", "start", "duration_ms",
+	}
+	if !reflect.DeepEqual(records[0], header) {
+		t.Errorf("header expected: %v, got: %v", header, records[0])
+	}
+
+	want := [][]string{
+		{"http://vulnerable.example.com", "vulnerable", "vulnerable", "", "false", "false", "true", "false", "", "2019-03-04T10:00:00Z", "1500.000"},
+		{"http://safe.example.com", "not_vulnerable", "safe", "/gateway", "false", "false", "false", "false", "", "2019-03-04T10:00:00Z", "0.000"},
+		{"http://unreachable.example.com", "unreachable", "unreachable", "", "false", "false", "false", "false", "network failure", "2019-03-04T10:00:00Z", "0.000"},
+	}
+	for i, n := range []int{1, 4, 5} {
+		if !reflect.DeepEqual(records[n], want[i]) {
+			t.Errorf("record %v expected: %v, got: %v", n, want[i], records[n])
+		}
+	}
+}
+
+func TestTextWriter(t *testing.T) {
+	defer func(v bool) {
+		verbose = v
+	}(verbose)
+	verbose = true
+
+	var b bytes.Buffer
+	w := &textWriter{out: nopCloser{&b}}
+	for _, r := range testResults() {
+		if err := w.Write(r); err != nil {
+			t.Fatalf("nil error expected, got %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("nil error expected, got %v", err)
+	}
+
+	want := "http://vulnerable.example.com is vulnerable\nnetwork failure\n"
+	if b.String() != want {
+		t.Errorf("output expected: %q, got: %q", want, b.String())
+	}
+}
@@ -5,12 +5,7 @@ Copyright 2019 Adevinta
 package cmd
 
 import (
-	"bufio"
 	"fmt"
-	"os"
-	"sync"
-
-	gozuul "github.com/adevinta/gozuul"
 
 	"github.com/spf13/cobra"
 )
@@ -19,16 +14,18 @@ import (
 var passiveCmd = &cobra.Command{
 	Use:   "passive <target>...",
 	Short: "Executes a new passive scan against the specified targets",
+	Long: `Executes a new passive scan against the specified targets.
+
+A target can be a URL, a host, a host:port pair, an IPv4 or IPv6 CIDR range,
+or "-" to read the targets from stdin. The hosts specified without a scheme
+are probed over https and http in the ports given by --ports, and the ones
+that don't answer are reported as unreachable.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		if len(args) < 1 {
 			return fmt.Errorf("incorrect number of args, want 1 at least, got %v", len(args))
 		}
 
-		targets := args[0:]
-
-		passiveScan(targets...)
-
-		return nil
+		return passiveScan(args...)
 	},
 }
 
@@ -36,6 +33,14 @@ var passiveCmd = &cobra.Command{
 var passiveBulkCmd = &cobra.Command{
 	Use:   "passivebulk <targets-file>",
 	Short: "Executes a new passive scan against the targets specified in a file",
+	Long: `Executes a new passive scan against the targets specified in a file, one per
+line, or in stdin if the file is "-". The targets are specified as in the
+passive command.
+
+The targets can also be imported from nmap XML reports, whose open HTTP and
+HTTPS services are scanned, and from HAR files and Burp Suite XML exports,
+whose requested sites are scanned. The format is detected from the contents of
+the file unless it is set with --input-format.`,
 	RunE: func(cmd *cobra.Command, args []string) error {
 		if len(args) != 1 {
 			return fmt.Errorf("incorrect number of args, want 1, got %v", len(args))
@@ -43,85 +48,36 @@ var passiveBulkCmd = &cobra.Command{
 
 		tf := args[0]
 
-		targets, err := readLines(tf)
+		specs, err := readSpecs(tf)
 		if err != nil {
 			return err
 		}
 
-		passiveScan(targets...)
-
-		return nil
+		return passiveScan(specs...)
 	},
 }
 
 func init() {
+	addTargetFlags(passiveCmd)
+	addTargetFlags(passiveBulkCmd)
+	addInputFormatFlag(passiveBulkCmd)
+
 	RootCmd.AddCommand(passiveCmd)
 	RootCmd.AddCommand(passiveBulkCmd)
 }
 
-func passiveScan(targets ...string) {
-	vulnerable := make(chan string, len(targets))
-	errors := make(chan error, len(targets))
-	done := make(chan bool)
-
-	go func() {
-		var wg sync.WaitGroup
-		rate := make(chan struct{}, 30)
-
-		for _, target := range targets {
-			rate <- struct{}{}
-			wg.Add(1)
-
-			go func(target string) {
-				defer func() {
-					<-rate
-					wg.Done()
-				}()
-
-				t := target
-
-				rs, err := gozuul.PassiveScan(t)
-				if err != nil {
-					errors <- err
-				} else if rs.Vulnerable {
-					vulnerable <- t
-				}
-			}(target)
-		}
-
-		wg.Wait()
-		done <- true
-
-	}()
-
-loop:
-	for {
-		select {
-		case target := <-vulnerable:
-			fmt.Printf("%v is vulnerable\n", target)
-		case err := <-errors:
-			if verbose {
-				fmt.Println(err)
-			}
-		case <-done:
-			break loop
-		}
+func passiveScan(specs ...string) error {
+	targets, unreachable, err := resolveTargets(specs, 30)
+	if err != nil {
+		return err
 	}
-}
 
-// readLines reads a whole file into memory
-// and returns a slice of its lines.
-func readLines(path string) ([]string, error) {
-	file, err := os.Open(path)
+	w, err := newResultWriter(false)
 	if err != nil {
-		return nil, err
+		return err
 	}
-	defer file.Close()
 
-	var lines []string
-	scanner := bufio.NewScanner(file)
-	for scanner.Scan() {
-		lines = append(lines, scanner.Text())
-	}
-	return lines, scanner.Err()
+	results := scanTargets(targets, unreachable, 30, newScanner().PassiveScanContext)
+
+	return writeResults(w, results)
 }
@@ -0,0 +1,315 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package cmd
+
+import (
+	"bufio"
+	"bytes"
+	"encoding/json"
+	"fmt"
+	htmltemplate "html/template"
+	"io"
+	"io/ioutil"
+	"sort"
+	"strings"
+	texttemplate "text/template"
+	"time"
+	"unicode/utf8"
+
+	gozuul "github.com/adevinta/gozuul"
+
+	"github.com/spf13/cobra"
+)
+
+const maxSnippetSize = 1024
+
+var reportFormat string
+
+// reportCmd represents the report command
+var reportCmd = &cobra.Command{
+	Use:   "report <results-file>...",
+	Short: "Renders a report from saved scan results",
+	Long: `Renders a standalone HTML or Markdown report from the scan results saved
+with the json or jsonl output formats.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 1 {
+			return fmt.Errorf("incorrect number of args, want 1 at least, got %v", len(args))
+		}
+
+		var results []scanResult
+		for _, path := range args {
+			rs, err := readResults(path)
+			if err != nil {
+				return err
+			}
+			results = append(results, rs...)
+		}
+
+		return writeReport(results)
+	},
+}
+
+func init() {
+	reportCmd.Flags().StringVar(&reportFormat, "format", "html", "format of the report: html or markdown")
+	RootCmd.AddCommand(reportCmd)
+}
+
+// readResults reads the scan results saved in a file, either as a JSON array
+// or as JSON Lines.
+func readResults(path string) ([]scanResult, error) {
+	b, err := ioutil.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	var results []scanResult
+
+	b = bytes.TrimSpace(b)
+	if bytes.HasPrefix(b, []byte("[")) {
+		if err := json.Unmarshal(b, &results); err != nil {
+			return nil, fmt.Errorf("unable to read results from %v: %w", path, err)
+		}
+		return results, nil
+	}
+
+	dec := json.NewDecoder(bytes.NewReader(b))
+	for {
+		var r scanResult
+		if err := dec.Decode(&r); err == io.EOF {
+			break
+		} else if err != nil {
+			return nil, fmt.Errorf("unable to read results from %v: %w", path, err)
+		}
+		results = append(results, r)
+	}
+
+	return results, nil
+}
+
+// reportData is the data rendered by the report templates.
+type reportData struct {
+	Generated   time.Time
+	Total       int
+	Counts      []verdictCount
+	Results     []scanResult
+	Findings    []scanResult
+	AdvisoryURL string
+	Remediation string
+}
+
+type verdictCount struct {
+	Verdict gozuul.Verdict
+	Count   int
+}
+
+// verdictRank orders the verdicts in the report, the most severe first.
+var verdictRank = map[gozuul.Verdict]int{
+	gozuul.VerdictVulnerable:         0,
+	gozuul.VerdictPossiblyVulnerable: 1,
+	gozuul.VerdictInconclusive:       2,
+	gozuul.VerdictAuthRequired:       3,
+	gozuul.VerdictAdminDisabled:      4,
+	gozuul.VerdictNotVulnerable:      5,
+	gozuul.VerdictNotZuul:            6,
+	gozuul.VerdictUnreachable:        7,
+	gozuul.VerdictUnknown:            8,
+}
+
+func newReportData(results []scanResult) reportData {
+	sort.SliceStable(results, func(i, j int) bool {
+		ri, rj := verdictRank[results[i].Verdict], verdictRank[results[j].Verdict]
+		if ri != rj {
+			return ri < rj
+		}
+		return results[i].Target < results[j].Target
+	})
+
+	counts := make(map[gozuul.Verdict]int)
+	var findings []scanResult
+	for _, r := range results {
+		counts[r.Verdict]++
+		if isFinding(r) {
+			findings = append(findings, r)
+		}
+	}
+
+	data := reportData{
+		Generated:   time.Now().UTC(),
+		Total:       len(results),
+		Results:     results,
+		Findings:    findings,
+		AdvisoryURL: advisoryURL,
+		Remediation: remediation,
+	}
+	for _, v := range gozuul.Verdicts() {
+		data.Counts = append(data.Counts, verdictCount{v, counts[v]})
+	}
+
+	return data
+}
+
+// snippet returns the beginning of an evidence body, cut at a rune boundary.
+func snippet(body string) string {
+	if len(body) <= maxSnippetSize {
+		return body
+	}
+
+	n := maxSnippetSize
+	for n > 0 && !utf8.RuneStart(body[n]) {
+		n--
+	}
+	return body[:n] + "..."
+}
+
+// fence returns a Markdown code fence longer than any run of backticks in the
+// text, so the text can not close it.
+func fence(s string) string {
+	longest, run := 0, 0
+	for _, c := range s {
+		if c != '`' {
+			run = 0
+			continue
+		}
+		run++
+		if run > longest {
+			longest = run
+		}
+	}
+
+	if longest < 3 {
+		return "```"
+	}
+	return strings.Repeat("`", longest+1)
+}
+
+// mdEscape escapes the text of a Markdown table cell.
+func mdEscape(s string) string {
+	r := strings.NewReplacer("|", `\|`, "\n", " ", "\r", "")
+	return r.Replace(s)
+}
+
+func writeReport(results []scanResult) error {
+	out, err := openOutput()
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	w := bufio.NewWriter(out)
+	data := newReportData(results)
+
+	switch reportFormat {
+	case "html":
+		err = htmlReport.Execute(w, data)
+	case "markdown":
+		err = markdownReport.Execute(w, data)
+	default:
+		err = fmt.Errorf("unknown report format: %v", reportFormat)
+	}
+	if err != nil {
+		return err
+	}
+
+	return w.Flush()
+}
+
+var reportFuncs = map[string]interface{}{
+	"snippet": snippet,
+	"fence":   fence,
+	"md":      mdEscape,
+	"date": func(t time.Time) string {
+		return t.Format(time.RFC1123)
+	},
+}
+
+const remediation = `The Zuul admin servlets allow uploading Groovy filters that are compiled
+and executed by the gateway (nflx-2016-003), what leads to remote code
+execution.
+
+1. Disable the admin servlets (FilterScriptManagerServlet and
+   filterLoader.jsp) or, if they are needed, restrict the access to them to
+   trusted networks and require authentication.
+2. Verify that the filter upload endpoint (/admin/scriptmanager) rejects
+   the upload requests, for instance scanning the gateways again with the
+   passive command.
+3. Review the filters deployed in the vulnerable gateways. Unknown filters
+   might have been planted by an attacker, so treat the gateway as compromised
+   until proved otherwise.`
+
+var htmlReport = htmltemplate.Must(htmltemplate.New("html").Funcs(reportFuncs).Parse(`<!DOCTYPE html>
+<html>
+<head>
+<meta charset="utf-8">
+<title>Gozuul scan report</title>
+<style>
+body { font-family: sans-serif; margin: 2em; color: #222; }
+table { border-collapse: collapse; margin-bottom: 2em; }
+th, td { border: 1px solid #ccc; padding: 0.3em 0.6em; text-align: left; vertical-align: top; }
+th { background: #eee; }
+pre { background: #f6f6f6; padding: 0.6em; white-space: pre-wrap; word-break: break-all; }
+.vulnerable { color: #b00; font-weight: bold; }
+.possibly_vulnerable { color: #c60; font-weight: bold; }
+</style>
+</head>
+<body>
+<h1>Gozuul scan report</h1>
+<p>Generated on {{date .Generated}}. {{.Total}} targets scanned for the <a href="{{.AdvisoryURL}}">nflx-2016-003</a> security advisory.</p>
+
+<h2>Summary</h2>
+<table>
+<tr><th>Verdict</th><th>Targets</th></tr>
+{{range .Counts}}<tr><td class="{{.Verdict}}">{{.Verdict}}</td><td>{{.Count}}</td></tr>
+{{end}}</table>
+
+<h2>Remediation</h2>
+<pre>{{.Remediation}}</pre>
+
+{{if .Findings}}<h2>Findings</h2>
+{{range .Findings}}<h3 class="{{.Verdict}}">{{.Target}}</h3>
+<p><b>{{.Verdict}}</b>: {{.Reason}}</p>
+{{range .Evidence}}<p>{{.Method}} {{.URL}}{{if .Status}} &rarr; {{.Status}}{{end}}{{if .Error}} &rarr; {{.Error}}{{end}}</p>
+{{if .Body}}<pre>{{snippet .Body}}</pre>
+{{end}}{{end}}{{end}}{{end}}
+<h2>Targets</h2>
+<table>
+<tr><th>Target</th><th>Verdict</th><th>Reason</th><th>Error</th><th>Duration (ms)</th></tr>
+{{range .Results}}<tr><td>{{.Target}}</td><td class="{{.Verdict}}">{{.Verdict}}</td><td>{{.Reason}}</td><td>{{.Error}}</td><td>{{printf "%.0f" .Duration}}</td></tr>
+{{end}}</table>
+</body>
+</html>
+`))
+
+var markdownReport = texttemplate.Must(texttemplate.New("markdown").Funcs(reportFuncs).Parse(`# Gozuul scan report
+
+Generated on {{date .Generated}}. {{.Total}} targets scanned for the [nflx-2016-003]({{.AdvisoryURL}}) security advisory.
+
+## Summary
+
+| Verdict | Targets |
+|---|---|
+{{range .Counts}}| {{.Verdict}} | {{.Count}} |
+{{end}}
+## Remediation
+
+{{.Remediation}}
+{{if .Findings}}
+## Findings
+{{range .Findings}}
+### {{.Target}}
+
+**{{.Verdict}}**: {{.Reason}}
+{{range .Evidence}}
+{{.Method}} {{.URL}}{{if .Status}} -> {{.Status}}{{end}}{{if .Error}} -> {{.Error}}{{end}}
+{{if .Body}}{{$body := snippet .Body}}
+{{fence $body}}
+{{$body}}
+{{fence $body}}
+{{end}}{{end}}{{end}}{{end}}
+## Targets
+
+| Target | Verdict | Reason | Error | Duration (ms) |
+|---|---|---|---|---|
+{{range .Results}}| {{md .Target}} | {{.Verdict}} | {{md .Reason}} | {{md .Error}} | {{printf "%.0f" .Duration}} |
+{{end}}`))
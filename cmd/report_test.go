@@ -0,0 +1,103 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"strings"
+	"testing"
+	"unicode/utf8"
+
+	gozuul "github.com/adevinta/gozuul"
+)
+
+func TestReportCounts(t *testing.T) {
+	results := append(testResults(), scanResult{
+		Target:    "http://another.example.com",
+		ResultSet: gozuul.ResultSet{Verdict: gozuul.VerdictVulnerable},
+	})
+
+	data := newReportData(results)
+
+	if data.Total != 6 {
+		t.Errorf("total expected: 6, got: %v", data.Total)
+	}
+	if len(data.Findings) != 3 {
+		t.Errorf("findings expected: 3, got: %v", len(data.Findings))
+	}
+
+	want := map[gozuul.Verdict]int{
+		gozuul.VerdictVulnerable:         2,
+		gozuul.VerdictPossiblyVulnerable: 1,
+		gozuul.VerdictAdminDisabled:      1,
+		gozuul.VerdictNotVulnerable:      1,
+		gozuul.VerdictUnreachable:        1,
+	}
+	if len(data.Counts) != len(gozuul.Verdicts()) {
+		t.Errorf("a count for every verdict expected, got: %v", data.Counts)
+	}
+	for _, c := range data.Counts {
+		if c.Count != want[c.Verdict] {
+			t.Errorf("%v: count expected: %v, got: %v", c.Verdict, want[c.Verdict], c.Count)
+		}
+	}
+
+	if data.Results[0].Target != "http://another.example.com" || data.Results[len(data.Results)-1].Verdict != gozuul.VerdictUnreachable {
+		t.Errorf("results sorted by verdict and target expected, got: %v", data.Results)
+	}
+}
+
+func TestReportEscaping(t *testing.T) {
+	results := []scanResult{{
+		Target: "http://vulnerable.example.com",
+		ResultSet: gozuul.ResultSet{
+			Verdict: gozuul.VerdictVulnerable,
+			Reason:  "upload <accepted> | banner\nreturned",
+			Evidence: []gozuul.Exchange{{
+				Method: "POST",
+				URL:    "http://vulnerable.example.com/admin/scriptmanager",
+				Status: 400,
+				Body:   "usage\n```\n# injected\n````",
+			}},
+		},
+	}}
+
+	var b bytes.Buffer
+	if err := markdownReport.Execute(&b, newReportData(results)); err != nil {
+		t.Fatalf("nil error expected, got %v", err)
+	}
+	md := b.String()
+
+	if !strings.Contains(md, "| upload <accepted> \\| banner returned |") {
+		t.Errorf("escaped reason expected in the targets table, got:\n%v", md)
+	}
+	if !strings.Contains(md, "\n`````\nusage\n```\n# injected\n````\n`````\n") {
+		t.Errorf("body fenced with five backticks expected, got:\n%v", md)
+	}
+
+	b.Reset()
+	if err := htmlReport.Execute(&b, newReportData(results)); err != nil {
+		t.Fatalf("nil error expected, got %v", err)
+	}
+	if html := b.String(); strings.Contains(html, "<accepted>") || !strings.Contains(html, "&lt;accepted&gt;") {
+		t.Errorf("escaped reason expected in the HTML report, got:\n%v", html)
+	}
+}
+
+func TestSnippet(t *testing.T) {
+	body := strings.Repeat("a", maxSnippetSize-1) + "é" + "tail"
+
+	got := snippet(body)
+	if !utf8.ValidString(got) {
+		t.Errorf("valid UTF-8 expected, got: %q", got[len(got)-8:])
+	}
+	if want := strings.Repeat("a", maxSnippetSize-1) + "..."; got != want {
+		t.Errorf("snippet cut before the split rune expected, got: %q", got[len(got)-8:])
+	}
+
+	if got := snippet("short"); got != "short" {
+		t.Errorf("snippet expected: %q, got: %q", "short", got)
+	}
+}
@@ -11,14 +11,33 @@ import (
 	"github.com/spf13/cobra"
 )
 
+// Exit codes used with the --exit-code flag.
+const (
+	exitVulnerable = 2
+	exitScanErrors = 3
+)
+
 var (
-	verbose bool
+	verbose      bool
+	outputFormat string
+	outputFile   string
+	evidence     bool
+	exitCode     bool
+	contextPaths []string
+
+	// foundVulnerable and scanFailed are set when a scan finds a vulnerable
+	// target or fails, respectively.
+	foundVulnerable bool
+	scanFailed      bool
 )
 
 // RootCmd represents the base command when called without any subcommands
 var RootCmd = &cobra.Command{
 	Use:   "gozuul",
 	Short: "Provides methods to scan Netflix Zuul instances in relation to the Netflix nflx-2016-003 Security Advisory",
+	PersistentPreRunE: func(cmd *cobra.Command, args []string) error {
+		return loadConfig()
+	},
 }
 
 // Execute adds all child commands to the root command sets flags appropriately.
@@ -28,8 +47,35 @@ func Execute() {
 		fmt.Println(err)
 		os.Exit(1)
 	}
+
+	if !exitCode {
+		return
+	}
+
+	if status := exitStatus(); status != 0 {
+		os.Exit(status)
+	}
+}
+
+// exitStatus returns the status the command exits with when --exit-code is
+// set: exitVulnerable if any target is vulnerable, exitScanErrors if any scan
+// failed, and 0 otherwise.
+func exitStatus() int {
+	switch {
+	case foundVulnerable:
+		return exitVulnerable
+	case scanFailed:
+		return exitScanErrors
+	}
+	return 0
 }
 
 func init() {
 	RootCmd.PersistentFlags().BoolVarP(&verbose, "verbose", "v", false, "prints verbose information during command execution")
+	RootCmd.PersistentFlags().StringVarP(&outputFormat, "output", "o", "text", "format of the scan results: text, json, jsonl, csv, sarif or junit")
+	RootCmd.PersistentFlags().StringVar(&outputFile, "output-file", "", "file the scan results are written to (default stdout)")
+	RootCmd.PersistentFlags().BoolVar(&exitCode, "exit-code", false, fmt.Sprintf("exits with %v if any target is vulnerable, or with %v if any scan fails", exitVulnerable, exitScanErrors))
+	RootCmd.PersistentFlags().BoolVar(&evidence, "evidence", false, "records the HTTP exchanges made during the scans in the results")
+	RootCmd.PersistentFlags().StringVar(&configFile, "config", "", "JSON configuration file, setting the paths of the admin endpoints")
+	RootCmd.PersistentFlags().StringSliceVar(&contextPaths, "context-paths", nil, `candidate context paths of the admin servlets probed before every scan, for instance "/,/gateway"`)
 }
@@ -0,0 +1,179 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+
+	gozuul "github.com/adevinta/gozuul"
+)
+
+const (
+	sarifSchema  = "https://json.schemastore.org/sarif-2.1.0.json"
+	sarifVersion = "2.1.0"
+	sarifRuleID  = "nflx-2016-003"
+	advisoryURL  = "https://github.com/Netflix/security-bulletins/blob/master/advisories/nflx-2016-003.md"
+	gozuulURL    = "https://github.com/adevinta/gozuul"
+)
+
+type sarifLog struct {
+	Schema  string     `json:"$schema"`
+	Version string     `json:"version"`
+	Runs    []sarifRun `json:"runs"`
+}
+
+type sarifRun struct {
+	Tool    sarifTool     `json:"tool"`
+	Results []sarifResult `json:"results"`
+}
+
+type sarifTool struct {
+	Driver sarifDriver `json:"driver"`
+}
+
+type sarifDriver struct {
+	Name           string      `json:"name"`
+	InformationURI string      `json:"informationUri"`
+	Rules          []sarifRule `json:"rules"`
+}
+
+type sarifRule struct {
+	ID                   string                 `json:"id"`
+	Name                 string                 `json:"name"`
+	ShortDescription     sarifMessage           `json:"shortDescription"`
+	FullDescription      sarifMessage           `json:"fullDescription"`
+	Help                 sarifMessage           `json:"help"`
+	HelpURI              string                 `json:"helpUri"`
+	DefaultConfiguration sarifConfiguration     `json:"defaultConfiguration"`
+	Properties           map[string]interface{} `json:"properties"`
+}
+
+type sarifConfiguration struct {
+	Level string `json:"level"`
+}
+
+type sarifMessage struct {
+	Text string `json:"text"`
+}
+
+type sarifResult struct {
+	RuleID     string                 `json:"ruleId"`
+	RuleIndex  int                    `json:"ruleIndex"`
+	Level      string                 `json:"level"`
+	Message    sarifMessage           `json:"message"`
+	Locations  []sarifLocation        `json:"locations"`
+	Properties map[string]interface{} `json:"properties"`
+}
+
+type sarifLocation struct {
+	PhysicalLocation sarifPhysicalLocation `json:"physicalLocation"`
+}
+
+type sarifPhysicalLocation struct {
+	ArtifactLocation sarifArtifactLocation `json:"artifactLocation"`
+}
+
+type sarifArtifactLocation struct {
+	URI string `json:"uri"`
+}
+
+var nflx2016003Rule = sarifRule{
+	ID:   sarifRuleID,
+	Name: "ZuulAdminFilterUpload",
+	ShortDescription: sarifMessage{
+		Text: "Netflix Zuul admin endpoints allow uploading arbitrary filters",
+	},
+	FullDescription: sarifMessage{
+		Text: "The Zuul admin script manager accepts the upload of Groovy filters, " +
+			"which are compiled and executed by the gateway, leading to remote code execution.",
+	},
+	Help: sarifMessage{
+		Text: "Disable the Zuul admin servlets, or restrict the access to them, " +
+			"as described in the nflx-2016-003 security advisory.",
+	},
+	HelpURI:              advisoryURL,
+	DefaultConfiguration: sarifConfiguration{Level: "error"},
+	Properties: map[string]interface{}{
+		"tags":              []string{"security", "rce"},
+		"security-severity": "9.8",
+	},
+}
+
+// sarifLevel maps the verdict of a scan to the level of its SARIF result. It
+// returns an empty level for the verdicts that are not reported.
+func sarifLevel(v gozuul.Verdict) string {
+	switch v {
+	case gozuul.VerdictVulnerable:
+		return "error"
+	case gozuul.VerdictPossiblyVulnerable:
+		return "warning"
+	}
+	return ""
+}
+
+// sarifWriter writes a SARIF log, with a result for every vulnerable or
+// possibly vulnerable target, when closed.
+type sarifWriter struct {
+	out     io.WriteCloser
+	results []sarifResult
+}
+
+func (w *sarifWriter) Write(r scanResult) error {
+	level := sarifLevel(r.Verdict)
+	if level == "" {
+		return nil
+	}
+
+	w.results = append(w.results, sarifResult{
+		RuleID:    sarifRuleID,
+		RuleIndex: 0,
+		Level:     level,
+		Message: sarifMessage{
+			Text: fmt.Sprintf("%v is %v: %v", r.Target, r.Verdict, r.Reason),
+		},
+		Locations: []sarifLocation{{
+			PhysicalLocation: sarifPhysicalLocation{
+				ArtifactLocation: sarifArtifactLocation{URI: r.Target},
+			},
+		}},
+		Properties: map[string]interface{}{
+			"verdict":          r.Verdict,
+			"prev_enabled":     r.PrevEnabled,
+			"admin_disabled":   r.AdminDisabled,
+			"vulnerable":       r.Vulnerable,
+			"might_vulnerable": r.MightVulnerable,
+		},
+	})
+
+	return nil
+}
+
+func (w *sarifWriter) Close() error {
+	log := sarifLog{
+		Schema:  sarifSchema,
+		Version: sarifVersion,
+		Runs: []sarifRun{{
+			Tool: sarifTool{
+				Driver: sarifDriver{
+					Name:           "gozuul",
+					InformationURI: gozuulURL,
+					Rules:          []sarifRule{nflx2016003Rule},
+				},
+			},
+			Results: w.results,
+		}},
+	}
+
+	enc := json.NewEncoder(w.out)
+	enc.SetIndent("", "  ")
+	if err := enc.Encode(log); err != nil {
+		w.out.Close()
+		return err
+	}
+
+	return w.out.Close()
+}
@@ -0,0 +1,87 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package cmd
+
+import (
+	"bytes"
+	"encoding/json"
+	"reflect"
+	"testing"
+)
+
+func TestSARIFWriter(t *testing.T) {
+	var b bytes.Buffer
+	w := &sarifWriter{out: nopCloser{&b}, results: []sarifResult{}}
+	for _, r := range testResults() {
+		if err := w.Write(r); err != nil {
+			t.Fatalf("nil error expected, got %v", err)
+		}
+	}
+	if err := w.Close(); err != nil {
+		t.Fatalf("nil error expected, got %v", err)
+	}
+
+	var log struct {
+		Schema  string `json:"$schema"`
+		Version string `json:"version"`
+		Runs    []struct {
+			Tool struct {
+				Driver struct {
+					Name  string `json:"name"`
+					Rules []struct {
+						ID string `json:"id"`
+					} `json:"rules"`
+				} `json:"driver"`
+			} `json:"tool"`
+			Results []struct {
+				RuleID    string `json:"ruleId"`
+				RuleIndex int    `json:"ruleIndex"`
+				Level     string `json:"level"`
+				Locations []struct {
+					PhysicalLocation struct {
+						ArtifactLocation struct {
+							URI string `json:"uri"`
+						} `json:"artifactLocation"`
+					} `json:"physicalLocation"`
+				} `json:"locations"`
+			} `json:"results"`
+		} `json:"runs"`
+	}
+	if err := json.Unmarshal(b.Bytes(), &log); err != nil {
+		t.Fatalf("valid JSON expected, got %v", err)
+	}
+
+	if log.Schema != "https://json.schemastore.org/sarif-2.1.0.json" || log.Version != "2.1.0" {
+		t.Errorf("SARIF 2.1.0 schema and version expected, got: %q, %q", log.Schema, log.Version)
+	}
+	if len(log.Runs) != 1 {
+		t.Fatalf("runs expected: 1, got: %v", len(log.Runs))
+	}
+
+	run := log.Runs[0]
+	if run.Tool.Driver.Name != "gozuul" {
+		t.Errorf("driver expected: gozuul, got: %q", run.Tool.Driver.Name)
+	}
+	if len(run.Tool.Driver.Rules) != 1 || run.Tool.Driver.Rules[0].ID != "nflx-2016-003" {
+		t.Errorf("nflx-2016-003 rule expected, got: %+v", run.Tool.Driver.Rules)
+	}
+
+	type result struct{ ruleID, level, uri string }
+	var got []result
+	for _, r := range run.Results {
+		if r.RuleIndex != 0 || len(r.Locations) != 1 {
+			t.Errorf("rule index 0 and a location expected, got: %+v", r)
+			continue
+		}
+		got = append(got, result{r.RuleID, r.Level, r.Locations[0].PhysicalLocation.ArtifactLocation.URI})
+	}
+	want := []result{
+		{"nflx-2016-003", "error", "http://vulnerable.example.com"},
+		{"nflx-2016-003", "warning", "http://possibly.example.com"},
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("results expected: %v, got: %v", want, got)
+	}
+}
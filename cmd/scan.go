@@ -0,0 +1,132 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package cmd
+
+import (
+	"context"
+	"os"
+	"os/signal"
+	"sync"
+	"time"
+
+	gozuul "github.com/adevinta/gozuul"
+	"github.com/adevinta/gozuul/targets"
+)
+
+// scanResult is the result of scanning a target.
+type scanResult struct {
+	Target string `json:"target"`
+	gozuul.ResultSet
+	Error    string    `json:"error,omitempty"`
+	Start    time.Time `json:"start"`
+	Duration float64   `json:"duration_ms"`
+}
+
+// newScanner returns the gozuul.Scanner configured by the flags, and by the
+// given options.
+func newScanner(extra ...gozuul.Option) *gozuul.Scanner {
+	var opts []gozuul.Option
+	if evidence {
+		opts = append(opts, gozuul.WithEvidence(gozuul.DefaultEvidenceConfig()))
+	}
+	if cfg.Endpoints != nil {
+		opts = append(opts, gozuul.WithEndpoints(*cfg.Endpoints))
+	}
+	if len(contextPaths) > 0 {
+		opts = append(opts, gozuul.WithContextPaths(contextPaths...))
+	}
+
+	return gozuul.NewScanner(append(opts, extra...)...)
+}
+
+// scanFunc scans a single target.
+type scanFunc func(ctx context.Context, target string) (gozuul.ResultSet, error)
+
+// scanTargets scans the targets using at most concurrency goroutines, and
+// sends the results through the returned channel, which is closed after the
+// last one. The unreachable targets are not scanned, and their results have
+// the unreachable verdict. The running scans are aborted on interrupt.
+func scanTargets(targets []string, unreachable []targets.Unreachable, concurrency int, scan scanFunc) <-chan scanResult {
+	results := make(chan scanResult, len(targets)+len(unreachable))
+
+	go func() {
+		defer close(results)
+
+		for _, u := range unreachable {
+			err := probeError(u)
+			results <- scanResult{
+				Target:    u.URL,
+				ResultSet: gozuul.ResultSet{Verdict: gozuul.VerdictUnreachable, Reason: err.Error()},
+				Error:     err.Error(),
+				Start:     time.Now(),
+			}
+		}
+
+		forEachTarget(targets, concurrency, func(ctx context.Context, target string) {
+			start := time.Now()
+			rs, err := scan(ctx, target)
+
+			r := scanResult{
+				Target:    target,
+				ResultSet: rs,
+				Start:     start,
+				Duration:  float64(time.Since(start)) / float64(time.Millisecond),
+			}
+			if err != nil {
+				r.Error = err.Error()
+			}
+
+			results <- r
+		})
+	}()
+
+	return results
+}
+
+// forEachTarget calls f for every target using at most concurrency
+// goroutines, and returns when all the calls have returned. The context
+// passed to f is cancelled on interrupt.
+func forEachTarget(targets []string, concurrency int, f func(ctx context.Context, target string)) {
+	ctx, cancel := interruptContext()
+	defer cancel()
+
+	var wg sync.WaitGroup
+	rate := make(chan struct{}, concurrency)
+
+	for _, target := range targets {
+		rate <- struct{}{}
+		wg.Add(1)
+
+		go func(target string) {
+			defer func() {
+				<-rate
+				wg.Done()
+			}()
+
+			f(ctx, target)
+		}(target)
+	}
+
+	wg.Wait()
+}
+
+// interruptContext returns a context that is cancelled on interrupt.
+func interruptContext() (context.Context, context.CancelFunc) {
+	ctx, cancel := context.WithCancel(context.Background())
+
+	sigs := make(chan os.Signal, 1)
+	signal.Notify(sigs, os.Interrupt)
+	go func() {
+		defer signal.Stop(sigs)
+
+		select {
+		case <-sigs:
+			cancel()
+		case <-ctx.Done():
+		}
+	}()
+
+	return ctx, cancel
+}
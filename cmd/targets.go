@@ -0,0 +1,91 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package cmd
+
+import (
+	"os"
+	"time"
+
+	gozuul "github.com/adevinta/gozuul"
+	"github.com/adevinta/gozuul/targets"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	probePorts   []int
+	probeTimeout time.Duration
+	inputFormat  string
+)
+
+// addTargetFlags adds the flags that configure how the targets are expanded
+// and probed.
+func addTargetFlags(c *cobra.Command) {
+	c.Flags().IntSliceVar(&probePorts, "ports", targets.DefaultPorts, "ports probed for the targets specified without a port")
+	c.Flags().DurationVar(&probeTimeout, "probe-timeout", 5*time.Second, "timeout of the requests probing the targets")
+}
+
+// addInputFormatFlag adds the flag that sets the format of the targets file
+// of the bulk commands.
+func addInputFormatFlag(c *cobra.Command) {
+	c.Flags().StringVar(&inputFormat, "input-format", string(targets.FormatAuto), "format of the targets file: auto, list, nmap, har or burp")
+}
+
+// readSpecs reads the target specifications from a file, or from stdin if
+// the path is "-", in the format set by --input-format.
+func readSpecs(path string) ([]string, error) {
+	format := targets.Format(inputFormat)
+
+	if path == "-" {
+		return targets.Read(os.Stdin, format)
+	}
+
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+
+	return targets.Read(f, format)
+}
+
+// resolveTargets expands the target specifications, reading them from stdin
+// for "-", and returns the base URLs of the services that answered the
+// probes and the targets that did not.
+func resolveTargets(specs []string, concurrency int) ([]string, []targets.Unreachable, error) {
+	var all []string
+	for _, spec := range specs {
+		if spec != "-" {
+			all = append(all, spec)
+			continue
+		}
+
+		stdin, err := readSpecs(spec)
+		if err != nil {
+			return nil, nil, err
+		}
+		all = append(all, stdin...)
+	}
+
+	ts, err := targets.Expand(all, probePorts)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	// The probes are sent as the scans, but with their own timeout.
+	client := newScanner(gozuul.WithTimeout(probeTimeout)).Client()
+
+	ctx, cancel := interruptContext()
+	defer cancel()
+
+	alive, unreachable := targets.Probe(ctx, client, ts, concurrency)
+	return alive, unreachable, nil
+}
+
+// probeError returns the error reported for a target that did not answer the
+// probes.
+func probeError(u targets.Unreachable) error {
+	return &gozuul.ScanError{Kind: gozuul.ErrNetwork, Phase: gozuul.PhaseProbe, URL: u.URL, Err: u.Err}
+}
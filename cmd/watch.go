@@ -0,0 +1,104 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package cmd
+
+import (
+	"errors"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/adevinta/gozuul/watch"
+
+	"github.com/spf13/cobra"
+)
+
+var (
+	watchInterval time.Duration
+	watchState    string
+	watchWebhooks []string
+)
+
+// watchCmd represents the watch command
+var watchCmd = &cobra.Command{
+	Use:   "watch <target>...",
+	Short: "Reports the filters and revisions that appear in the specified targets",
+	Long: `Polls the filter loaders of the specified targets at the given interval,
+until interrupted, and reports every filter ID or revision that appears in
+them. The filters found the first time a target is polled are recorded without
+reporting them. The revisions seen are stored in the state file, so they are
+not reported again when the command is restarted. Only GET requests are made
+to the targets.
+
+Every event is written as a line of JSON, for instance:
+
+  {"time":"2019-03-04T10:00:00Z","target":"http://www.adevinta.com","type":"new_revision","id":"origin:Routing:route","revisions":[3]}
+
+and posted as JSON to the webhooks given with --webhook. The type of the
+events is new_filter or new_revision. The events that can not be written or
+posted are reported again on the next poll.
+
+The targets are specified as in the passive command.`,
+	RunE: func(cmd *cobra.Command, args []string) error {
+		if len(args) < 1 {
+			return fmt.Errorf("incorrect number of args, want 1 at least, got %v", len(args))
+		}
+
+		return watchTargets(args...)
+	},
+}
+
+func init() {
+	watchCmd.Flags().DurationVar(&watchInterval, "interval", 5*time.Minute, "interval between polls")
+	watchCmd.Flags().StringVar(&watchState, "state", "gozuul-watch.json", "file the revisions seen are stored in")
+	watchCmd.Flags().StringSliceVar(&watchWebhooks, "webhook", nil, "URL the events are posted to")
+	addTargetFlags(watchCmd)
+	RootCmd.AddCommand(watchCmd)
+}
+
+func watchTargets(specs ...string) error {
+	if watchInterval <= 0 {
+		return fmt.Errorf("invalid interval: %v", watchInterval)
+	}
+
+	targets, unreachable, err := resolveTargets(specs, 30)
+	if err != nil {
+		return err
+	}
+
+	out, err := openOutput()
+	if err != nil {
+		return err
+	}
+	defer out.Close()
+
+	sinks := []watch.Sink{watch.NewWriterSink(out)}
+	for _, u := range watchWebhooks {
+		sinks = append(sinks, &watch.WebhookSink{URL: u, Client: &http.Client{Timeout: 30 * time.Second}})
+	}
+
+	w := &watch.Watcher{
+		Scanner:   newScanner(),
+		Targets:   targets,
+		Interval:  watchInterval,
+		StatePath: watchState,
+		Sinks:     sinks,
+		ErrorLog:  log.New(os.Stderr, "", log.LstdFlags),
+	}
+	for _, u := range unreachable {
+		w.ErrorLog.Printf("%v: %v", u.URL, probeError(u))
+	}
+
+	ctx, cancel := interruptContext()
+	defer cancel()
+
+	if err := w.Run(ctx); err != nil && !errors.Is(err, ctx.Err()) {
+		return err
+	}
+
+	return out.Close()
+}
@@ -0,0 +1,223 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+// Package discovery discovers the Zuul instances to scan from the service
+// registries and orchestrators where they are deployed.
+package discovery
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"net/http"
+	"strconv"
+	"strings"
+
+	"github.com/adevinta/gozuul/targets"
+)
+
+// Instance is an instance registered in Eureka. Port and SecurePort are 0
+// when they are not enabled.
+type Instance struct {
+	App        string
+	HostName   string
+	IPAddr     string
+	Status     string
+	Port       int
+	SecurePort int
+	Metadata   map[string]string
+}
+
+// StatusUp is the status of the instances that are ready to receive traffic.
+const StatusUp = "UP"
+
+// URL returns the base URL of the instance, the secure one if its secure port
+// is enabled. It returns an empty string if the instance has no host or no
+// port enabled.
+func (i Instance) URL() string {
+	host := i.HostName
+	if host == "" {
+		host = i.IPAddr
+	}
+
+	switch {
+	case host == "":
+		return ""
+	case i.SecurePort != 0:
+		return targets.BaseURL("https", host, i.SecurePort)
+	case i.Port != 0:
+		return targets.BaseURL("http", host, i.Port)
+	}
+
+	return ""
+}
+
+// IsZuul reports whether the app name or the metadata of the instance
+// indicate that it is a Zuul gateway.
+func IsZuul(i Instance) bool {
+	if strings.Contains(strings.ToLower(i.App), "zuul") {
+		return true
+	}
+
+	for k, v := range i.Metadata {
+		if strings.Contains(strings.ToLower(k), "zuul") || strings.Contains(strings.ToLower(v), "zuul") {
+			return true
+		}
+	}
+
+	return false
+}
+
+// eurekaApps is the response of the /eureka/apps endpoint. Eureka encodes
+// the lists with a single element as objects, so they are decoded with
+// oneOrMany.
+type eurekaApps struct {
+	Applications struct {
+		Application oneOrMany `json:"application"`
+	} `json:"applications"`
+}
+
+type eurekaApp struct {
+	Name     string    `json:"name"`
+	Instance oneOrMany `json:"instance"`
+}
+
+type eurekaInstance struct {
+	App        string            `json:"app"`
+	HostName   string            `json:"hostName"`
+	IPAddr     string            `json:"ipAddr"`
+	Status     string            `json:"status"`
+	Port       eurekaPort        `json:"port"`
+	SecurePort eurekaPort        `json:"securePort"`
+	Metadata   map[string]string `json:"metadata"`
+}
+
+// eurekaPort is a port of an instance. Depending on the version of Eureka,
+// the number and the enabled flag are encoded as strings or not.
+type eurekaPort struct {
+	Number  json.RawMessage `json:"$"`
+	Enabled json.RawMessage `json:"@enabled"`
+}
+
+func (p eurekaPort) port() int {
+	enabled := strings.Trim(string(p.Enabled), `"`)
+	if enabled != "true" {
+		return 0
+	}
+
+	n, err := strconv.Atoi(strings.Trim(string(p.Number), `"`))
+	if err != nil {
+		return 0
+	}
+
+	return n
+}
+
+// oneOrMany is a JSON list that might be encoded as a single object.
+type oneOrMany []json.RawMessage
+
+func (l *oneOrMany) UnmarshalJSON(b []byte) error {
+	b = bytes.TrimSpace(b)
+	if bytes.HasPrefix(b, []byte("[")) {
+		var list []json.RawMessage
+		if err := json.Unmarshal(b, &list); err != nil {
+			return err
+		}
+		*l = list
+		return nil
+	}
+	if bytes.Equal(b, []byte("null")) {
+		*l = nil
+		return nil
+	}
+
+	*l = oneOrMany{json.RawMessage(b)}
+	return nil
+}
+
+// EurekaInstances returns the instances registered in the Eureka server. The
+// registry URL can be the base URL of the server or the URL of its REST API,
+// ending with /eureka.
+func EurekaInstances(ctx context.Context, client *http.Client, registryURL string) ([]Instance, error) {
+	appsURL := strings.TrimSuffix(registryURL, "/")
+	if !strings.HasSuffix(appsURL, "/eureka") {
+		appsURL += "/eureka"
+	}
+	appsURL += "/apps"
+
+	req, err := http.NewRequestWithContext(ctx, "GET", appsURL, nil)
+	if err != nil {
+		return nil, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	res, err := client.Do(req)
+	if err != nil {
+		return nil, err
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unexpected status code from %v: %v", appsURL, res.StatusCode)
+	}
+
+	var apps eurekaApps
+	if err := json.NewDecoder(res.Body).Decode(&apps); err != nil {
+		return nil, fmt.Errorf("unable to decode the Eureka apps: %w", err)
+	}
+
+	var instances []Instance
+	for _, rawApp := range apps.Applications.Application {
+		var app eurekaApp
+		if err := json.Unmarshal(rawApp, &app); err != nil {
+			return nil, fmt.Errorf("unable to decode the Eureka apps: %w", err)
+		}
+
+		for _, rawInstance := range app.Instance {
+			var ei eurekaInstance
+			if err := json.Unmarshal(rawInstance, &ei); err != nil {
+				return nil, fmt.Errorf("unable to decode the Eureka instances of %v: %w", app.Name, err)
+			}
+
+			i := Instance{
+				App:        ei.App,
+				HostName:   ei.HostName,
+				IPAddr:     ei.IPAddr,
+				Status:     ei.Status,
+				Port:       ei.Port.port(),
+				SecurePort: ei.SecurePort.port(),
+				Metadata:   ei.Metadata,
+			}
+			if i.App == "" {
+				i.App = app.Name
+			}
+			instances = append(instances, i)
+		}
+	}
+
+	return instances, nil
+}
+
+// EurekaTargets returns the base URLs of the instances registered in the
+// Eureka server that match, one per instance and without duplicates. The
+// instances whose status is not UP are skipped unless allStatuses is true.
+func EurekaTargets(ctx context.Context, client *http.Client, registryURL string, match func(Instance) bool, allStatuses bool) ([]string, error) {
+	instances, err := EurekaInstances(ctx, client, registryURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var urls []string
+	for _, i := range instances {
+		if !allStatuses && !strings.EqualFold(i.Status, StatusUp) {
+			continue
+		}
+		if match(i) {
+			urls = append(urls, i.URL())
+		}
+	}
+
+	return targets.Dedup(urls), nil
+}
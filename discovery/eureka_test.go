@@ -0,0 +1,137 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package discovery
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const eurekaAppsJSON = `{
+  "applications": {
+    "versions__delta": "1",
+    "apps__hashcode": "UP_4_",
+    "application": [
+      {
+        "name": "ZUUL",
+        "instance": [
+          {
+            "instanceId": "zuul-1",
+            "hostName": "zuul-1.internal",
+            "app": "ZUUL",
+            "ipAddr": "10.0.0.1",
+            "status": "UP",
+            "port": {"$": 8080, "@enabled": "true"},
+            "securePort": {"$": 443, "@enabled": "false"},
+            "metadata": {"management.port": "8081"}
+          },
+          {
+            "instanceId": "zuul-2",
+            "hostName": "",
+            "app": "ZUUL",
+            "ipAddr": "10.0.0.2",
+            "status": "UP",
+            "port": {"$": "8080", "@enabled": "true"},
+            "securePort": {"$": "8443", "@enabled": "true"}
+          },
+          {
+            "instanceId": "zuul-3",
+            "hostName": "zuul-3.internal",
+            "app": "ZUUL",
+            "ipAddr": "10.0.0.5",
+            "status": "OUT_OF_SERVICE",
+            "port": {"$": 8080, "@enabled": "true"},
+            "securePort": {"$": 443, "@enabled": "false"}
+          }
+        ]
+      },
+      {
+        "name": "EDGE",
+        "instance": {
+          "instanceId": "edge-1",
+          "hostName": "edge-1.internal",
+          "app": "EDGE",
+          "ipAddr": "10.0.0.3",
+          "status": "UP",
+          "port": {"$": 80, "@enabled": true},
+          "securePort": {"$": 443, "@enabled": false},
+          "metadata": {"gateway": "spring-cloud-netflix-zuul"}
+        }
+      },
+      {
+        "name": "USERS",
+        "instance": {
+          "instanceId": "users-1",
+          "hostName": "users-1.internal",
+          "app": "USERS",
+          "ipAddr": "10.0.0.4",
+          "status": "UP",
+          "port": {"$": 8080, "@enabled": "true"},
+          "securePort": {"$": 443, "@enabled": "false"}
+        }
+      }
+    ]
+  }
+}`
+
+func TestEurekaTargets(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/eureka/apps" {
+			http.NotFound(w, r)
+			return
+		}
+		if !strings.Contains(r.Header.Get("Accept"), "application/json") {
+			t.Errorf("JSON response expected, got Accept: %v", r.Header.Get("Accept"))
+		}
+		w.Header().Set("Content-Type", "application/json")
+		w.Write([]byte(eurekaAppsJSON))
+	}))
+	defer ts.Close()
+
+	want := []string{
+		"http://zuul-1.internal:8080",
+		"https://10.0.0.2:8443",
+		"http://edge-1.internal",
+	}
+
+	for _, registry := range []string{ts.URL, ts.URL + "/eureka/"} {
+		got, err := EurekaTargets(context.Background(), ts.Client(), registry, IsZuul, false)
+		if err != nil {
+			t.Fatalf("unexpected error: %v", err)
+		}
+		if !reflect.DeepEqual(got, want) {
+			t.Errorf("targets mismatch for registry %v: got %v, want %v", registry, got, want)
+		}
+	}
+
+	want = []string{
+		"http://zuul-1.internal:8080",
+		"https://10.0.0.2:8443",
+		"http://zuul-3.internal:8080",
+		"http://edge-1.internal",
+	}
+	got, err := EurekaTargets(context.Background(), ts.Client(), ts.URL, IsZuul, true)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("targets mismatch for all the statuses: got %v, want %v", got, want)
+	}
+}
+
+func TestEurekaTargetsError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		w.WriteHeader(http.StatusUnauthorized)
+	}))
+	defer ts.Close()
+
+	if _, err := EurekaTargets(context.Background(), ts.Client(), ts.URL, IsZuul, false); err == nil {
+		t.Errorf("error expected for unauthorized registry")
+	}
+}
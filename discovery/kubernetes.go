@@ -0,0 +1,326 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package discovery
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strings"
+
+	"github.com/adevinta/gozuul/targets"
+
+	yaml "gopkg.in/yaml.v2"
+)
+
+// DefaultClusterDomain is the default domain of the Kubernetes clusters.
+const DefaultClusterDomain = "cluster.local"
+
+// workloadKinds are the kinds of the Kubernetes objects that run pods.
+var workloadKinds = map[string]bool{
+	"Deployment":  true,
+	"StatefulSet": true,
+	"DaemonSet":   true,
+	"ReplicaSet":  true,
+}
+
+// Workload is a Kubernetes object that runs pods, such as a Deployment.
+type Workload struct {
+	Kind      string
+	Name      string
+	Namespace string
+	Labels    map[string]string
+	PodLabels map[string]string
+	Images    []string
+}
+
+// IsZuulWorkload reports whether the images or the labels of the workload
+// indicate that it runs a Zuul gateway.
+func IsZuulWorkload(w Workload) bool {
+	for _, image := range w.Images {
+		if strings.Contains(strings.ToLower(image), "zuul") {
+			return true
+		}
+	}
+
+	for _, labels := range []map[string]string{w.Labels, w.PodLabels} {
+		for k, v := range labels {
+			if strings.Contains(strings.ToLower(k), "zuul") || strings.Contains(strings.ToLower(v), "zuul") {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// k8sObject holds the fields of the Deployments, Services and Ingresses
+// needed to find the Zuul gateways. Lists of objects are decoded as well.
+type k8sObject struct {
+	Kind     string `yaml:"kind"`
+	Metadata struct {
+		Name      string            `yaml:"name"`
+		Namespace string            `yaml:"namespace"`
+		Labels    map[string]string `yaml:"labels"`
+	} `yaml:"metadata"`
+	Spec struct {
+		// Workloads.
+		Template struct {
+			Metadata struct {
+				Labels map[string]string `yaml:"labels"`
+			} `yaml:"metadata"`
+			Spec struct {
+				Containers []struct {
+					Image string `yaml:"image"`
+				} `yaml:"containers"`
+			} `yaml:"spec"`
+		} `yaml:"template"`
+
+		// Services.
+		Selector map[string]interface{} `yaml:"selector"`
+		Ports    []struct {
+			Name     string `yaml:"name"`
+			Port     int    `yaml:"port"`
+			Protocol string `yaml:"protocol"`
+		} `yaml:"ports"`
+
+		// Ingresses.
+		TLS []struct {
+			Hosts []string `yaml:"hosts"`
+		} `yaml:"tls"`
+		Rules []struct {
+			Host string `yaml:"host"`
+			HTTP struct {
+				Paths []struct {
+					Path    string         `yaml:"path"`
+					Backend ingressBackend `yaml:"backend"`
+				} `yaml:"paths"`
+			} `yaml:"http"`
+		} `yaml:"rules"`
+	} `yaml:"spec"`
+	Items []k8sObject `yaml:"items"`
+}
+
+// ingressBackend is the backend of an Ingress, as defined by the
+// extensions/v1beta1 and networking.k8s.io/v1 APIs.
+type ingressBackend struct {
+	ServiceName string `yaml:"serviceName"`
+	Service     struct {
+		Name string `yaml:"name"`
+	} `yaml:"service"`
+}
+
+func (b ingressBackend) serviceName() string {
+	if b.ServiceName != "" {
+		return b.ServiceName
+	}
+	return b.Service.Name
+}
+
+// manifests are the objects read from the manifests.
+type manifests struct {
+	workloads []k8sObject
+	services  []k8sObject
+	ingresses []k8sObject
+}
+
+func (m *manifests) add(o k8sObject) {
+	if o.Metadata.Namespace == "" {
+		o.Metadata.Namespace = "default"
+	}
+
+	switch {
+	case o.Kind == "List":
+		for _, item := range o.Items {
+			m.add(item)
+		}
+	case workloadKinds[o.Kind]:
+		m.workloads = append(m.workloads, o)
+	case o.Kind == "Service":
+		m.services = append(m.services, o)
+	case o.Kind == "Ingress":
+		m.ingresses = append(m.ingresses, o)
+	}
+}
+
+// ManifestError is a manifest file, or one of its documents, that could not
+// be decoded.
+type ManifestError struct {
+	Path string
+	Err  error
+}
+
+func (e *ManifestError) Error() string {
+	return fmt.Sprintf("unable to read manifest %v: %v", e.Path, e.Err)
+}
+
+func (e *ManifestError) Unwrap() error {
+	return e.Err
+}
+
+// readManifests reads the objects of the YAML manifests in the directory and
+// its subdirectories. The documents that cannot be decoded are skipped and
+// returned as errors. A syntax error also skips the rest of its file, as the
+// decoder cannot resume after it.
+func readManifests(dir string) (*manifests, []*ManifestError, error) {
+	m := &manifests{}
+	var skipped []*ManifestError
+
+	err := filepath.Walk(dir, func(path string, info os.FileInfo, err error) error {
+		if err != nil {
+			return err
+		}
+		ext := filepath.Ext(path)
+		if info.IsDir() || (ext != ".yaml" && ext != ".yml") {
+			return nil
+		}
+
+		b, err := ioutil.ReadFile(path)
+		if err != nil {
+			return err
+		}
+
+		dec := yaml.NewDecoder(bytes.NewReader(b))
+		for {
+			var o k8sObject
+			err := dec.Decode(&o)
+			if err == io.EOF {
+				break
+			}
+			if err != nil {
+				skipped = append(skipped, &ManifestError{Path: path, Err: err})
+				if _, ok := err.(*yaml.TypeError); ok {
+					continue
+				}
+				break
+			}
+			m.add(o)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return m, skipped, nil
+}
+
+// KubernetesTargets reads the Kubernetes manifests in the directory and
+// returns, without duplicates, the in-cluster URLs of the Services that
+// select the pods of the matching workloads, followed by the URLs of the
+// Ingresses that route to those Services. The manifests that cannot be
+// decoded are skipped and returned along with the URLs.
+func KubernetesTargets(dir, clusterDomain string, match func(Workload) bool) ([]string, []*ManifestError, error) {
+	m, skipped, err := readManifests(dir)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var zuuls []k8sObject
+	for _, o := range m.workloads {
+		w := Workload{
+			Kind:      o.Kind,
+			Name:      o.Metadata.Name,
+			Namespace: o.Metadata.Namespace,
+			Labels:    o.Metadata.Labels,
+			PodLabels: o.Spec.Template.Metadata.Labels,
+		}
+		for _, c := range o.Spec.Template.Spec.Containers {
+			w.Images = append(w.Images, c.Image)
+		}
+		if match(w) {
+			zuuls = append(zuuls, o)
+		}
+	}
+
+	var urls []string
+
+	// services indexes the Zuul Services by namespace and name.
+	services := make(map[string]bool)
+	for _, svc := range m.services {
+		if !selectsAny(svc, zuuls) {
+			continue
+		}
+		services[svc.Metadata.Namespace+"/"+svc.Metadata.Name] = true
+
+		host := fmt.Sprintf("%v.%v.svc.%v", svc.Metadata.Name, svc.Metadata.Namespace, clusterDomain)
+		for _, p := range svc.Spec.Ports {
+			if p.Protocol != "" && p.Protocol != "TCP" {
+				continue
+			}
+			urls = append(urls, targets.BaseURL(portScheme(p.Name, p.Port), host, p.Port))
+		}
+	}
+
+	for _, ing := range m.ingresses {
+		tlsHosts := make(map[string]bool)
+		for _, tls := range ing.Spec.TLS {
+			for _, h := range tls.Hosts {
+				tlsHosts[h] = true
+			}
+		}
+
+		for _, rule := range ing.Spec.Rules {
+			if rule.Host == "" {
+				continue
+			}
+			scheme := "http"
+			if tlsHosts[rule.Host] {
+				scheme = "https"
+			}
+
+			for _, p := range rule.HTTP.Paths {
+				if !services[ing.Metadata.Namespace+"/"+p.Backend.serviceName()] {
+					continue
+				}
+
+				path := strings.TrimRight(strings.TrimSuffix(p.Path, "*"), "/")
+				urls = append(urls, scheme+"://"+rule.Host+path)
+			}
+		}
+	}
+
+	return targets.Dedup(urls), skipped, nil
+}
+
+// selectsAny reports whether the selector of the Service matches the pod
+// labels of any of the workloads in its namespace.
+func selectsAny(svc k8sObject, workloads []k8sObject) bool {
+	if len(svc.Spec.Selector) == 0 {
+		return false
+	}
+
+	for _, w := range workloads {
+		if w.Metadata.Namespace != svc.Metadata.Namespace {
+			continue
+		}
+
+		labels := w.Spec.Template.Metadata.Labels
+		selected := true
+		for k, v := range svc.Spec.Selector {
+			if lv, ok := labels[k]; !ok || lv != fmt.Sprint(v) {
+				selected = false
+				break
+			}
+		}
+		if selected {
+			return true
+		}
+	}
+
+	return false
+}
+
+// portScheme guesses the scheme of a Service port from its name and number.
+func portScheme(name string, port int) string {
+	if strings.Contains(strings.ToLower(name), "https") || port == 443 || port == 8443 {
+		return "https"
+	}
+	return "http"
+}
@@ -0,0 +1,220 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package discovery
+
+import (
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var k8sManifests = map[string]string{
+	"gateway/deployment.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: gateway
+  namespace: edge
+spec:
+  selector:
+    matchLabels:
+      app: gateway
+  template:
+    metadata:
+      labels:
+        app: gateway
+        tier: edge
+    spec:
+      containers:
+      - name: gateway
+        image: registry.example.com/netflix-zuul:1.3.1
+        ports:
+        - containerPort: 8080
+`,
+	"gateway/service.yml": `apiVersion: v1
+kind: Service
+metadata:
+  name: gateway
+  namespace: edge
+spec:
+  selector:
+    app: gateway
+  ports:
+  - name: http
+    port: 80
+    targetPort: 8080
+  - name: https
+    port: 8443
+  - name: metrics
+    port: 9090
+    protocol: UDP
+---
+apiVersion: networking.k8s.io/v1
+kind: Ingress
+metadata:
+  name: gateway
+  namespace: edge
+spec:
+  tls:
+  - hosts:
+    - api.example.com
+  rules:
+  - host: api.example.com
+    http:
+      paths:
+      - path: /
+        backend:
+          service:
+            name: gateway
+            port:
+              number: 80
+  - host: legacy.example.com
+    http:
+      paths:
+      - path: /zuul/*
+        backend:
+          serviceName: gateway
+          servicePort: http
+      - path: /users
+        backend:
+          serviceName: users
+          servicePort: 80
+`,
+	"labelled.yaml": `apiVersion: v1
+kind: List
+items:
+- apiVersion: apps/v1
+  kind: StatefulSet
+  metadata:
+    name: proxy
+    labels:
+      component: zuul
+  spec:
+    template:
+      metadata:
+        labels:
+          app: proxy
+      spec:
+        containers:
+        - image: proxy:latest
+- apiVersion: v1
+  kind: Service
+  metadata:
+    name: proxy
+  spec:
+    selector:
+      app: proxy
+    ports:
+    - port: 8080
+`,
+	"users.yaml": `apiVersion: apps/v1
+kind: Deployment
+metadata:
+  name: users
+  namespace: edge
+spec:
+  template:
+    metadata:
+      labels:
+        app: users
+    spec:
+      containers:
+      - image: users:2.0
+---
+apiVersion: v1
+kind: Service
+metadata:
+  name: users
+  namespace: edge
+spec:
+  selector:
+    app: users
+  ports:
+  - port: 80
+`,
+	"README.md": "not a manifest: [",
+}
+
+func TestKubernetesTargets(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gozuul-k8s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	for name, content := range k8sManifests {
+		path := filepath.Join(dir, name)
+		if err := os.MkdirAll(filepath.Dir(path), 0755); err != nil {
+			t.Fatal(err)
+		}
+		if err := ioutil.WriteFile(path, []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, skipped, err := KubernetesTargets(dir, DefaultClusterDomain, IsZuulWorkload)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+	if len(skipped) != 0 {
+		t.Errorf("unexpected skipped manifests: %v", skipped)
+	}
+
+	want := []string{
+		"http://gateway.edge.svc.cluster.local",
+		"https://gateway.edge.svc.cluster.local:8443",
+		"http://proxy.default.svc.cluster.local:8080",
+		"https://api.example.com",
+		"http://legacy.example.com/zuul",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("targets mismatch: got %v, want %v", got, want)
+	}
+}
+
+func TestKubernetesTargetsInvalidManifest(t *testing.T) {
+	dir, err := ioutil.TempDir("", "gozuul-k8s")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	files := map[string]string{
+		"broken.yaml":     "kind: [Deployment",
+		"deployment.yaml": k8sManifests["gateway/deployment.yaml"],
+		// The Service after the mistyped document must still be read.
+		"service.yaml": "kind: [Service]\n---\n" + k8sManifests["gateway/service.yml"],
+	}
+	for name, content := range files {
+		if err := ioutil.WriteFile(filepath.Join(dir, name), []byte(content), 0644); err != nil {
+			t.Fatal(err)
+		}
+	}
+
+	got, skipped, err := KubernetesTargets(dir, DefaultClusterDomain, IsZuulWorkload)
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{
+		"http://gateway.edge.svc.cluster.local",
+		"https://gateway.edge.svc.cluster.local:8443",
+		"https://api.example.com",
+		"http://legacy.example.com/zuul",
+	}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("targets mismatch: got %v, want %v", got, want)
+	}
+
+	var paths []string
+	for _, e := range skipped {
+		paths = append(paths, filepath.Base(e.Path))
+	}
+	wantPaths := []string{"broken.yaml", "service.yaml"}
+	if !reflect.DeepEqual(paths, wantPaths) {
+		t.Errorf("skipped manifests mismatch: got %v, want %v", paths, wantPaths)
+	}
+}
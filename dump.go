@@ -0,0 +1,221 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package gozuul
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"os"
+	"path/filepath"
+	"regexp"
+	"strings"
+)
+
+// ManifestFile is the name of the manifest written by DumpFilters.
+const ManifestFile = "manifest.json"
+
+// unsafeFileChars matches the characters of a filter ID that are replaced
+// when building the name of its file.
+var unsafeFileChars = regexp.MustCompile(`[^A-Za-z0-9._-]`)
+
+// FilterSource is the source code of a revision of a filter. Err is the error
+// that prevented downloading it, in which case Source is nil.
+type FilterSource struct {
+	Filter   Filter
+	Revision int
+	Source   []byte
+	Err      error
+}
+
+// Manifest describes the filters written to a directory by DumpFilters.
+type Manifest struct {
+	Target  string          `json:"target"`
+	Prefix  string          `json:"prefix,omitempty"`
+	Filters []ManifestEntry `json:"filters"`
+}
+
+// ManifestEntry is a revision of a filter written by DumpFilters. File is
+// relative to the directory of the manifest, and SHA256 is the hex encoded
+// hash of its contents. When the revision could not be downloaded, File and
+// SHA256 are empty and Error describes why.
+type ManifestEntry struct {
+	ID       string `json:"id"`
+	Revision int    `json:"revision"`
+	File     string `json:"file,omitempty"`
+	SHA256   string `json:"sha256,omitempty"`
+	Error    string `json:"error,omitempty"`
+}
+
+// FilterSources downloads the source of every revision of the filters of the
+// target using the default Scanner.
+func FilterSources(target string) ([]FilterSource, error) {
+	return defaultScanner.FilterSources(target)
+}
+
+// FilterSourcesContext is like FilterSources but honors the cancellation and
+// deadline of the given context.
+func FilterSourcesContext(ctx context.Context, target string) ([]FilterSource, error) {
+	return defaultScanner.FilterSourcesContext(ctx, target)
+}
+
+// FilterSources downloads the source of every revision of the filters of the
+// target.
+func (s *Scanner) FilterSources(target string) ([]FilterSource, error) {
+	return s.FilterSourcesContext(context.Background(), target)
+}
+
+// FilterSourcesContext downloads the source of every revision of the filters
+// listed by the filter loader of the target, following the DOWNLOAD links of
+// the filter loader page that point to the target. The revisions that can not
+// be downloaded are returned with the error in their Err field. Every HTTP
+// request honors the cancellation and deadline of the given context.
+func (s *Scanner) FilterSourcesContext(ctx context.Context, target string) ([]FilterSource, error) {
+	sources, _, err := s.filterSources(ctx, target)
+	return sources, err
+}
+
+func (s *Scanner) filterSources(ctx context.Context, target string) (sources []FilterSource, prefix string, err error) {
+	if target == "" {
+		return nil, "", fmt.Errorf("target can not be empty, target: %s", target)
+	}
+
+	prefix, err = s.adminPrefix(ctx, target)
+	if err != nil {
+		return nil, "", err
+	}
+	base := target + prefix
+
+	filters, err := s.listFilters(ctx, base+s.endpoints.FilterLoader)
+	if err != nil {
+		return nil, "", err
+	}
+
+	for _, f := range filters {
+		for _, r := range f.Revisions {
+			src, err := s.downloadFilter(ctx, target, r)
+			if err != nil && ctx.Err() != nil {
+				return nil, "", err
+			}
+			sources = append(sources, FilterSource{Filter: f, Revision: r.Revision, Source: src, Err: err})
+		}
+	}
+
+	return sources, prefix, nil
+}
+
+// downloadFilter downloads the source of a revision of a filter from its
+// download link. The links that point outside the target are not followed.
+func (s *Scanner) downloadFilter(ctx context.Context, target string, r FilterRevision) ([]byte, error) {
+	if r.Download == "" {
+		return nil, fmt.Errorf("no %v link for revision %v in the filter loader page", downloadAction, r.Revision)
+	}
+	URL := r.Download
+
+	if !sameOrigin(target, URL) {
+		return nil, fmt.Errorf("%v link for revision %v points outside the target: %v", downloadAction, r.Revision, URL)
+	}
+
+	tin, err := s.quickGet(ctx, PhaseDownload, URL)
+	if err != nil {
+		return nil, err
+	}
+
+	if tin.status != http.StatusOK {
+		return nil, &ScanError{Kind: ErrUnexpectedStatus, Phase: PhaseDownload, URL: URL, StatusCode: tin.status}
+	}
+
+	return []byte(tin.body), nil
+}
+
+// sameOrigin reports whether both URLs have the same scheme and host.
+func sameOrigin(a, b string) bool {
+	ua, err := url.Parse(a)
+	if err != nil {
+		return false
+	}
+	ub, err := url.Parse(b)
+	if err != nil {
+		return false
+	}
+	return strings.EqualFold(ua.Scheme, ub.Scheme) && strings.EqualFold(ua.Host, ub.Host)
+}
+
+// DumpFilters writes the source of every revision of the filters of the
+// target to dir using the default Scanner.
+func DumpFilters(target, dir string) (Manifest, error) {
+	return defaultScanner.DumpFilters(target, dir)
+}
+
+// DumpFiltersContext is like DumpFilters but honors the cancellation and
+// deadline of the given context.
+func DumpFiltersContext(ctx context.Context, target, dir string) (Manifest, error) {
+	return defaultScanner.DumpFiltersContext(ctx, target, dir)
+}
+
+// DumpFilters writes the source of every revision of the filters of the
+// target to dir.
+func (s *Scanner) DumpFilters(target, dir string) (Manifest, error) {
+	return s.DumpFiltersContext(context.Background(), target, dir)
+}
+
+// DumpFiltersContext downloads the source of every revision of the filters
+// of the target, as FilterSourcesContext does, and writes them to dir, which
+// is created if it does not exist. Every revision is written to its own file,
+// named after the ID and the revision of the filter, and the returned
+// manifest is written to the ManifestFile of dir. The revisions that can not
+// be downloaded are recorded in the manifest with their error.
+func (s *Scanner) DumpFiltersContext(ctx context.Context, target, dir string) (m Manifest, err error) {
+	sources, prefix, err := s.filterSources(ctx, target)
+	if err != nil {
+		return m, err
+	}
+
+	if err := os.MkdirAll(dir, 0755); err != nil {
+		return m, err
+	}
+
+	m = Manifest{Target: target, Prefix: prefix, Filters: []ManifestEntry{}}
+	used := make(map[string]bool)
+	for _, src := range sources {
+		if src.Err != nil {
+			m.Filters = append(m.Filters, ManifestEntry{ID: src.Filter.ID, Revision: src.Revision, Error: src.Err.Error()})
+			continue
+		}
+
+		// Different IDs can have the same safe name, so a counter is added to
+		// the names already used.
+		stem := fmt.Sprintf("%s-%d", unsafeFileChars.ReplaceAllString(src.Filter.ID, "_"), src.Revision)
+		name := stem + ".groovy"
+		for i := 2; used[name]; i++ {
+			name = fmt.Sprintf("%s.%d.groovy", stem, i)
+		}
+		used[name] = true
+
+		if err := ioutil.WriteFile(filepath.Join(dir, name), src.Source, 0644); err != nil {
+			return m, err
+		}
+
+		sum := sha256.Sum256(src.Source)
+		m.Filters = append(m.Filters, ManifestEntry{
+			ID:       src.Filter.ID,
+			Revision: src.Revision,
+			File:     name,
+			SHA256:   hex.EncodeToString(sum[:]),
+		})
+	}
+
+	b, err := json.MarshalIndent(m, "", "  ")
+	if err != nil {
+		return m, err
+	}
+
+	return m, ioutil.WriteFile(filepath.Join(dir, ManifestFile), append(b, '\n'), 0644)
+}
@@ -0,0 +1,200 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package gozuul
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"testing"
+)
+
+var testSources = map[string]string{
+	"origin:Routing:route/1": "class Routing extends ZuulFilter {}\n",
+	"origin:Routing:route/2": "class Routing extends ZuulFilter { int filterOrder() { 10 } }\n",
+	"origin:Debug:pre/1":     "class Debug extends ZuulFilter {}\n",
+}
+
+func scriptManager(w http.ResponseWriter, r *http.Request) {
+	q := r.URL.Query()
+	if q.Get("action") != "DOWNLOAD" {
+		vulnerable(w, r)
+		return
+	}
+
+	src, ok := testSources[q.Get("filter_id")+"/"+q.Get("revision")]
+	if !ok {
+		http.Error(w, "", 500)
+		return
+	}
+	fmt.Fprint(w, src)
+}
+
+func TestDumpFilters(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", notFound)
+	mux.HandleFunc("/admin/filterLoader.jsp", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprint(w, filterLoaderTable)
+	})
+	mux.HandleFunc("/admin/scriptmanager", scriptManager)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "gozuul")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	m, err := DumpFilters(ts.URL, dir)
+	if err != nil {
+		t.Fatalf("nil error expected, got %v", err)
+	}
+
+	wantFiles := []string{"origin_Routing_route-1.groovy", "origin_Routing_route-2.groovy", "origin_Debug_pre-1.groovy"}
+	var gotFiles []string
+	for _, e := range m.Filters {
+		gotFiles = append(gotFiles, e.File)
+
+		src, err := ioutil.ReadFile(filepath.Join(dir, e.File))
+		if err != nil {
+			t.Fatal(err)
+		}
+		if want := testSources[fmt.Sprintf("%v/%v", e.ID, e.Revision)]; string(src) != want {
+			t.Errorf("source of %v revision %v expected: %q, got: %q", e.ID, e.Revision, want, src)
+		}
+		if sum := sha256.Sum256(src); e.SHA256 != hex.EncodeToString(sum[:]) {
+			t.Errorf("hash of %v expected: %x, got: %v", e.File, sum, e.SHA256)
+		}
+	}
+	if !reflect.DeepEqual(gotFiles, wantFiles) {
+		t.Errorf("files expected: %v, got: %v", wantFiles, gotFiles)
+	}
+
+	b, err := ioutil.ReadFile(filepath.Join(dir, ManifestFile))
+	if err != nil {
+		t.Fatal(err)
+	}
+	var saved Manifest
+	if err := json.Unmarshal(b, &saved); err != nil {
+		t.Fatal(err)
+	}
+	if !reflect.DeepEqual(saved, m) {
+		t.Errorf("manifest expected: %+v, got: %+v", m, saved)
+	}
+}
+
+func TestDumpFiltersDownloadError(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", notFound)
+	mux.HandleFunc("/admin/filterLoader.jsp", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, vcheckFilter, 1)
+	})
+	mux.HandleFunc("/admin/scriptmanager", scriptManager)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	sources, err := FilterSources(ts.URL)
+	if err != nil {
+		t.Fatalf("nil error expected, got %v", err)
+	}
+	if len(sources) != 1 {
+		t.Fatalf("sources expected: 1, got: %v", len(sources))
+	}
+
+	var serr *ScanError
+	if !errors.As(sources[0].Err, &serr) || serr.Kind != ErrUnexpectedStatus || serr.Phase != PhaseDownload {
+		t.Errorf("unexpected status error in the download phase expected, got: %v", sources[0].Err)
+	}
+}
+
+func TestDumpFiltersPartial(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", notFound)
+	mux.HandleFunc("/admin/filterLoader.jsp", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, vcheckFilter, 1)
+		fmt.Fprint(w, `<a href="/downloads/source?action=DOWNLOAD&filter_id=origin:Routing:route&revision=2">DOWNLOAD</a>`)
+		fmt.Fprint(w, `<a href="scriptmanager?action=ACTIVATE&filter_id=origin:Debug:pre&revision=1">ACTIVATE</a>`)
+	})
+	mux.HandleFunc("/admin/scriptmanager", scriptManager)
+	mux.HandleFunc("/downloads/source", scriptManager)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "gozuul")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	m, err := DumpFilters(ts.URL, dir)
+	if err != nil {
+		t.Fatalf("nil error expected, got %v", err)
+	}
+	if len(m.Filters) != 3 {
+		t.Fatalf("manifest entries expected: 3, got: %+v", m.Filters)
+	}
+
+	// The revisions that fail are recorded without stopping the dump.
+	for _, e := range []ManifestEntry{m.Filters[0], m.Filters[2]} {
+		if e.Error == "" || e.File != "" || e.SHA256 != "" {
+			t.Errorf("error and no file expected for %v revision %v, got: %+v", e.ID, e.Revision, e)
+		}
+	}
+
+	e := m.Filters[1]
+	if e.ID != "origin:Routing:route" || e.Revision != 2 || e.Error != "" {
+		t.Fatalf("downloaded revision expected, got: %+v", e)
+	}
+	src, err := ioutil.ReadFile(filepath.Join(dir, e.File))
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := testSources["origin:Routing:route/2"]; string(src) != want {
+		t.Errorf("source expected: %q, got: %q", want, src)
+	}
+}
+
+func TestFilterSourcesOffHost(t *testing.T) {
+	offHost := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		t.Errorf("off-host download link followed: %v", r.URL)
+		scriptManager(w, r)
+	}))
+	defer offHost.Close()
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", notFound)
+	mux.HandleFunc("/admin/filterLoader.jsp", func(w http.ResponseWriter, r *http.Request) {
+		fmt.Fprintf(w, vcheckFilter, 1)
+		fmt.Fprintf(w, `<a href="%v/admin/scriptmanager?action=DOWNLOAD&filter_id=origin:Routing:route&revision=2">DOWNLOAD</a>`, offHost.URL)
+	})
+	mux.HandleFunc("/admin/scriptmanager", scriptManager)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	sources, err := FilterSources(ts.URL)
+	if err != nil {
+		t.Fatalf("nil error expected, got %v", err)
+	}
+	if len(sources) != 2 {
+		t.Fatalf("sources expected: 2, got: %+v", sources)
+	}
+
+	src := sources[1]
+	if src.Filter.ID != "origin:Routing:route" || src.Revision != 2 {
+		t.Fatalf("off-host revision expected, got: %+v", src)
+	}
+	if src.Err == nil || src.Source != nil {
+		t.Errorf("error and no source expected, got: %+v", src)
+	}
+}
@@ -0,0 +1,58 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package gozuul
+
+// Endpoints are the paths of the Zuul admin servlets, relative to the admin
+// prefix of the target, and the path of the check endpoint served by the
+// Vulncheck filter.
+// FilterLoader is the page listing the filters, ScriptManager is the servlet
+// that activates and deactivates them, and Upload is the URL of the script
+// manager that uploads them, including its query. The Vulncheck filter
+// answers the requests whose path contains "vulncheck-spt", so Check must
+// contain it.
+type Endpoints struct {
+	FilterLoader  string `json:"filter_loader"`
+	ScriptManager string `json:"script_manager"`
+	Upload        string `json:"upload"`
+	Check         string `json:"check"`
+}
+
+// DefaultEndpoints returns the paths of the admin servlets of a standard Zuul
+// deployment.
+func DefaultEndpoints() Endpoints {
+	return Endpoints{
+		FilterLoader:  filtersEndpoint,
+		ScriptManager: setFilterEndpoint,
+		Upload:        uploadEndpoint,
+		Check:         vcheckEndpoint,
+	}
+}
+
+// withDefaults returns the endpoints with the empty paths set to their
+// default values.
+func (e Endpoints) withDefaults() Endpoints {
+	d := DefaultEndpoints()
+	if e.FilterLoader == "" {
+		e.FilterLoader = d.FilterLoader
+	}
+	if e.ScriptManager == "" {
+		e.ScriptManager = d.ScriptManager
+	}
+	if e.Upload == "" {
+		e.Upload = d.Upload
+	}
+	if e.Check == "" {
+		e.Check = d.Check
+	}
+	return e
+}
+
+// WithEndpoints sets the paths of the admin servlets and of the check
+// endpoint used by the Scanner. The empty paths keep their default values.
+func WithEndpoints(e Endpoints) Option {
+	return func(s *Scanner) {
+		s.endpoints = e.withDefaults()
+	}
+}
@@ -0,0 +1,55 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package gozuul
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEndpoints(t *testing.T) {
+	var uploadAction string
+
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", notFound)
+	mux.HandleFunc("/ops/scripts", func(w http.ResponseWriter, r *http.Request) {
+		uploadAction = r.URL.Query().Get("action")
+		vulnerable(w, r)
+	})
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	rs, err := NewScanner().PassiveScan(ts.URL)
+	if err != nil {
+		t.Fatalf("nil error expected, got %v", err)
+	}
+	if rs.Verdict != VerdictNotZuul {
+		t.Errorf("verdict expected with the default endpoints: %v, got: %v", VerdictNotZuul, rs.Verdict)
+	}
+
+	s := NewScanner(WithEndpoints(Endpoints{
+		ScriptManager: "/ops/scripts",
+		Upload:        "/ops/scripts?action=UPLOAD",
+	}))
+
+	rs, err = s.PassiveScan(ts.URL)
+	if err != nil {
+		t.Fatalf("nil error expected, got %v", err)
+	}
+	if rs.Verdict != VerdictVulnerable {
+		t.Errorf("verdict expected with the custom endpoints: %v, got: %v", VerdictVulnerable, rs.Verdict)
+	}
+	if uploadAction != "UPLOAD" {
+		t.Errorf("upload action expected: UPLOAD, got: %q", uploadAction)
+	}
+
+	want := DefaultEndpoints()
+	want.ScriptManager = "/ops/scripts"
+	want.Upload = "/ops/scripts?action=UPLOAD"
+	if s.endpoints != want {
+		t.Errorf("endpoints expected: %+v, got: %+v", want, s.endpoints)
+	}
+}
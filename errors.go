@@ -0,0 +1,84 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package gozuul
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Errors that can be used with errors.Is to classify the errors returned by
+// the scans.
+var (
+	// ErrNetwork means that an HTTP request to the target failed.
+	ErrNetwork = errors.New("network failure")
+	// ErrUnexpectedStatus means that the target answered with a status code
+	// the scan does not know how to handle.
+	ErrUnexpectedStatus = errors.New("unexpected status code")
+	// ErrFilterLoaderParse means that the filter loader page could not be
+	// parsed.
+	ErrFilterLoaderParse = errors.New("filter loader parse failure")
+	// ErrRevisionNotIncreased means that the revision of the Vulncheck filter
+	// didn't increase after uploading it.
+	ErrRevisionNotIncreased = errors.New("revision didn't increase after filter upload")
+	// ErrActivationTimeout means that the uploaded filter did not become
+	// active in time.
+	ErrActivationTimeout = errors.New("filter seems to have been uploaded but not activated")
+	// ErrDeactivation means that the uploaded filter could not be deactivated.
+	ErrDeactivation = errors.New("filter deactivation failure")
+)
+
+// Phase identifies the step of a scan in which an error happened.
+type Phase string
+
+// Phases of a scan.
+const (
+	PhaseDiscoverPrefix Phase = "discover_prefix"
+	PhaseUpload         Phase = "upload"
+	PhaseCheckFilter    Phase = "check_filter"
+	PhaseListFilters    Phase = "list_filters"
+	PhaseActivate       Phase = "activate"
+	PhaseDeactivate     Phase = "deactivate"
+	PhaseFingerprint    Phase = "fingerprint"
+	PhaseDownload       Phase = "download"
+	PhaseProbe          Phase = "probe"
+)
+
+// ScanError is the error returned when a scan fails. Kind is one of the
+// exported Err values, so the error can be classified using errors.Is, and Err
+// is the underlying error, if any.
+type ScanError struct {
+	Kind       error
+	Phase      Phase
+	URL        string
+	StatusCode int
+	Err        error
+}
+
+func (e *ScanError) Error() string {
+	var b strings.Builder
+
+	fmt.Fprintf(&b, "%v when accessing %s (phase: %s", e.Kind, e.URL, e.Phase)
+	if e.StatusCode != 0 {
+		fmt.Fprintf(&b, ", status: %d", e.StatusCode)
+	}
+	b.WriteString(")")
+	if e.Err != nil {
+		fmt.Fprintf(&b, ": %v", e.Err)
+	}
+
+	return b.String()
+}
+
+// Unwrap returns the underlying error.
+func (e *ScanError) Unwrap() error {
+	return e.Err
+}
+
+// Is reports whether the kind of the error is target.
+func (e *ScanError) Is(target error) bool {
+	return e.Kind == target
+}
@@ -0,0 +1,71 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package gozuul
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/julienschmidt/httprouter"
+)
+
+func TestScanErrorDetails(t *testing.T) {
+	mux := httprouter.New()
+	mux.Handle("GET", vcheckEndpoint, adaptHandler(notFound))
+	mux.Handle("GET", filtersEndpoint, adaptHandler(internalServerError))
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	_, err := ActiveScan(ts.URL, "", make(chan bool, 1))
+
+	var serr *ScanError
+	if !errors.As(err, &serr) {
+		t.Fatalf("*ScanError expected, got: %v", err)
+	}
+	if !errors.Is(err, ErrUnexpectedStatus) {
+		t.Errorf("error kind expected: %v, got: %v", ErrUnexpectedStatus, serr.Kind)
+	}
+	if errors.Is(err, ErrNetwork) {
+		t.Errorf("error kind not expected: %v", ErrNetwork)
+	}
+	if serr.Phase != PhaseListFilters {
+		t.Errorf("phase expected: %v, got: %v", PhaseListFilters, serr.Phase)
+	}
+	if serr.URL != ts.URL+filtersEndpoint {
+		t.Errorf("URL expected: %v, got: %v", ts.URL+filtersEndpoint, serr.URL)
+	}
+	if serr.StatusCode != 500 {
+		t.Errorf("status code expected: %v, got: %v", 500, serr.StatusCode)
+	}
+}
+
+func TestScanErrorBody(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The connection is closed before sending the announced body.
+		w.Header().Set("Content-Length", "1024")
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, vulnerableDork)
+	}))
+	defer ts.Close()
+
+	_, err := PassiveScan(ts.URL)
+
+	var serr *ScanError
+	if !errors.As(err, &serr) {
+		t.Fatalf("*ScanError expected, got: %v", err)
+	}
+	if !errors.Is(err, ErrNetwork) {
+		t.Errorf("error kind expected: %v, got: %v", ErrNetwork, serr.Kind)
+	}
+	if serr.Phase != PhaseUpload {
+		t.Errorf("phase expected: %v, got: %v", PhaseUpload, serr.Phase)
+	}
+	if serr.URL != ts.URL+uploadEndpoint {
+		t.Errorf("URL expected: %v, got: %v", ts.URL+uploadEndpoint, serr.URL)
+	}
+}
@@ -0,0 +1,179 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package gozuul
+
+import (
+	"bytes"
+	"context"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"sync"
+)
+
+const (
+	defaultMaxBodySize = 4096
+	redactedValue      = "REDACTED"
+)
+
+// Exchange is the record of an HTTP request made during a scan and of its
+// response. Error is set instead of the response fields when the request
+// failed.
+type Exchange struct {
+	Method          string      `json:"method"`
+	URL             string      `json:"url"`
+	RequestHeaders  http.Header `json:"request_headers,omitempty"`
+	Status          int         `json:"status,omitempty"`
+	ResponseHeaders http.Header `json:"response_headers,omitempty"`
+	Body            string      `json:"body,omitempty"`
+	Truncated       bool        `json:"truncated,omitempty"`
+	Error           string      `json:"error,omitempty"`
+}
+
+// EvidenceConfig configures the evidence recorded by a Scanner.
+// MaxBodySize is the maximum number of bytes of the response bodies that are
+// recorded. Headers is the list of request and response headers that are
+// recorded; all of them are recorded when it is empty. The values of the
+// headers in Redact are replaced before being recorded.
+type EvidenceConfig struct {
+	MaxBodySize int
+	Headers     []string
+	Redact      []string
+}
+
+// DefaultEvidenceConfig returns an EvidenceConfig that records every header,
+// redacting the cookies and the credentials, and the first 4KB of the
+// response bodies.
+func DefaultEvidenceConfig() EvidenceConfig {
+	return EvidenceConfig{
+		MaxBodySize: defaultMaxBodySize,
+		Redact:      []string{"Cookie", "Set-Cookie", "Authorization", "Proxy-Authorization"},
+	}
+}
+
+// WithEvidence makes the Scanner record every HTTP exchange made during a scan
+// in the Evidence field of the resulting ResultSet.
+func WithEvidence(cfg EvidenceConfig) Option {
+	return func(s *Scanner) {
+		if cfg.MaxBodySize <= 0 {
+			cfg.MaxBodySize = defaultMaxBodySize
+		}
+		s.evidence = &cfg
+	}
+}
+
+// recorder stores the exchanges made during a single scan.
+type recorder struct {
+	mu        sync.Mutex
+	exchanges []Exchange
+}
+
+type recorderKey struct{}
+
+// withRecorder returns a context carrying a new recorder if the Scanner
+// records evidence. The recorder returned is nil otherwise.
+func (s *Scanner) withRecorder(ctx context.Context) (context.Context, *recorder) {
+	if s.evidence == nil {
+		return ctx, nil
+	}
+
+	rec := &recorder{}
+	return context.WithValue(ctx, recorderKey{}, rec), rec
+}
+
+// evidence returns the exchanges stored in the recorder.
+func (rec *recorder) evidence() []Exchange {
+	if rec == nil {
+		return nil
+	}
+
+	rec.mu.Lock()
+	defer rec.mu.Unlock()
+
+	return rec.exchanges
+}
+
+// record stores the exchange of the given request in the recorder of its
+// context, if any. The body of the response is read and replaced, so it can
+// still be read by the caller. If reading it fails, the replaced body returns
+// the same error after the bytes read.
+func (s *Scanner) record(req *http.Request, res *http.Response, err error) {
+	rec, ok := req.Context().Value(recorderKey{}).(*recorder)
+	if !ok || s.evidence == nil {
+		return
+	}
+
+	ex := Exchange{
+		Method:         req.Method,
+		URL:            req.URL.String(),
+		RequestHeaders: s.filterHeaders(req.Header),
+	}
+
+	if err != nil {
+		ex.Error = err.Error()
+	} else {
+		ex.Status = res.StatusCode
+		ex.ResponseHeaders = s.filterHeaders(res.Header)
+
+		body, rerr := ioutil.ReadAll(res.Body)
+		res.Body.Close()
+		if rerr != nil {
+			ex.Error = rerr.Error()
+			res.Body = ioutil.NopCloser(io.MultiReader(bytes.NewReader(body), errReader{rerr}))
+		} else {
+			res.Body = ioutil.NopCloser(bytes.NewReader(body))
+		}
+
+		if len(body) > s.evidence.MaxBodySize {
+			body = body[:s.evidence.MaxBodySize]
+			ex.Truncated = true
+		}
+		ex.Body = string(body)
+	}
+
+	rec.mu.Lock()
+	rec.exchanges = append(rec.exchanges, ex)
+	rec.mu.Unlock()
+}
+
+// errReader is an io.Reader that always fails with err.
+type errReader struct {
+	err error
+}
+
+func (r errReader) Read(p []byte) (int, error) {
+	return 0, r.err
+}
+
+// filterHeaders returns a copy of the headers configured to be recorded, with
+// the values of the headers configured to be redacted replaced.
+func (s *Scanner) filterHeaders(h http.Header) http.Header {
+	fh := http.Header{}
+
+	if len(s.evidence.Headers) == 0 {
+		for k, v := range h {
+			fh[k] = append([]string(nil), v...)
+		}
+	} else {
+		for _, k := range s.evidence.Headers {
+			if v := h.Values(k); len(v) > 0 {
+				fh[http.CanonicalHeaderKey(k)] = append([]string(nil), v...)
+			}
+		}
+	}
+
+	for _, k := range s.evidence.Redact {
+		k = http.CanonicalHeaderKey(k)
+		for i := range fh[k] {
+			fh[k][i] = redactedValue
+		}
+	}
+
+	if len(fh) == 0 {
+		return nil
+	}
+
+	return fh
+}
@@ -0,0 +1,107 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package gozuul
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestEvidence(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.SetCookie(w, &http.Cookie{Name: "session", Value: "secret"})
+		w.Header().Set("X-Zuul", "zuul")
+		vulnerable(w, r)
+	}))
+	defer ts.Close()
+
+	cfg := DefaultEvidenceConfig()
+	cfg.MaxBodySize = 16
+	s := NewScanner(WithEvidence(cfg), WithUserAgent("gozuul-test"))
+
+	rs, err := s.PassiveScan(ts.URL)
+	if err != nil {
+		t.Fatalf("nil error expected, got %v", err)
+	}
+	if !rs.Vulnerable {
+		t.Errorf("vulnerable expected: true, got: %v", rs.Vulnerable)
+	}
+	if len(rs.Evidence) != 1 {
+		t.Fatalf("exchanges expected: 1, got: %v", len(rs.Evidence))
+	}
+
+	ex := rs.Evidence[0]
+	if ex.Method != "POST" || ex.URL != ts.URL+uploadEndpoint {
+		t.Errorf("request expected: POST %v, got: %v %v", ts.URL+uploadEndpoint, ex.Method, ex.URL)
+	}
+	if ex.Status != http.StatusBadRequest {
+		t.Errorf("status expected: %v, got: %v", http.StatusBadRequest, ex.Status)
+	}
+	if got := ex.ResponseHeaders.Get("Set-Cookie"); got != redactedValue {
+		t.Errorf("redacted Set-Cookie expected, got: %q", got)
+	}
+	if got := ex.ResponseHeaders.Get("X-Zuul"); got != "zuul" {
+		t.Errorf("X-Zuul header expected: %q, got: %q", "zuul", got)
+	}
+	if got := ex.RequestHeaders.Get("User-Agent"); got != "gozuul-test" {
+		t.Errorf("User-Agent header expected: %q, got: %q", "gozuul-test", got)
+	}
+	if len(ex.Body) != 16 || !ex.Truncated {
+		t.Errorf("body truncated to 16 bytes expected, got: %q (truncated: %v)", ex.Body, ex.Truncated)
+	}
+
+	cfg = DefaultEvidenceConfig()
+	cfg.Headers = []string{"X-Zuul"}
+	rs, err = NewScanner(WithEvidence(cfg)).PassiveScan(ts.URL)
+	if err != nil {
+		t.Fatalf("nil error expected, got %v", err)
+	}
+	if got := rs.Evidence[0].ResponseHeaders; len(got) != 1 || got.Get("X-Zuul") != "zuul" {
+		t.Errorf("only X-Zuul header expected, got: %v", got)
+	}
+
+	rs, err = NewScanner().PassiveScan(ts.URL)
+	if err != nil {
+		t.Fatalf("nil error expected, got %v", err)
+	}
+	if rs.Evidence != nil {
+		t.Errorf("no evidence expected, got: %v", rs.Evidence)
+	}
+}
+
+func TestEvidenceBodyError(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		// The connection is closed before sending the announced body.
+		w.Header().Set("Content-Length", "1024")
+		w.WriteHeader(http.StatusBadRequest)
+		fmt.Fprint(w, vulnerableDork)
+	}))
+	defer ts.Close()
+
+	s := NewScanner(WithEvidence(DefaultEvidenceConfig()))
+
+	rs, err := s.PassiveScan(ts.URL)
+	if !errors.Is(err, io.ErrUnexpectedEOF) {
+		t.Fatalf("error expected: %v, got %v", io.ErrUnexpectedEOF, err)
+	}
+	if rs.Vulnerable {
+		t.Errorf("vulnerable expected: false, got: %v", rs.Vulnerable)
+	}
+	if len(rs.Evidence) != 1 {
+		t.Fatalf("exchanges expected: 1, got: %v", len(rs.Evidence))
+	}
+
+	ex := rs.Evidence[0]
+	if ex.Body != vulnerableDork {
+		t.Errorf("partial body expected: %q, got: %q", vulnerableDork, ex.Body)
+	}
+	if ex.Error == "" {
+		t.Errorf("error of the body expected in the exchange")
+	}
+}
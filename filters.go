@@ -0,0 +1,367 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package gozuul
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"net/url"
+	"sort"
+	"strconv"
+	"strings"
+
+	"golang.org/x/net/html"
+)
+
+// downloadAction is the action of the links of the filter loader that
+// download the source of a revision of a filter from the script manager.
+const downloadAction = "DOWNLOAD"
+
+// Filter is a filter known by a Zuul instance, as listed in its filter loader
+// page. The ID of a filter is made of the application, the name and the type
+// of the filter, for instance "origin:Vulncheck:pre".
+type Filter struct {
+	ID        string           `json:"id"`
+	Name      string           `json:"name"`
+	Type      string           `json:"type"`
+	Revisions []FilterRevision `json:"revisions"`
+}
+
+// FilterRevision is a revision of a filter. Active, Canary, Order and Created
+// are only set when the filter loader page shows them. Download is the
+// absolute URL of the link of the page that downloads the source of the
+// revision, if any.
+type FilterRevision struct {
+	Revision int    `json:"revision"`
+	Active   bool   `json:"active"`
+	Canary   bool   `json:"canary"`
+	Order    int    `json:"order,omitempty"`
+	Created  string `json:"created,omitempty"`
+	Download string `json:"download,omitempty"`
+}
+
+// Latest returns the biggest revision of the filter.
+func (f Filter) Latest() int {
+	latest := 0
+	for _, r := range f.Revisions {
+		if r.Revision > latest {
+			latest = r.Revision
+		}
+	}
+	return latest
+}
+
+// ListFilters returns the filters known by the target using the default
+// Scanner.
+func ListFilters(target string) ([]Filter, error) {
+	return defaultScanner.ListFilters(target)
+}
+
+// ListFiltersContext is like ListFilters but honors the cancellation and
+// deadline of the given context.
+func ListFiltersContext(ctx context.Context, target string) ([]Filter, error) {
+	return defaultScanner.ListFiltersContext(ctx, target)
+}
+
+// ListFilters returns the filters known by the target, with all their
+// revisions, scraping its filter loader page.
+func (s *Scanner) ListFilters(target string) ([]Filter, error) {
+	return s.ListFiltersContext(context.Background(), target)
+}
+
+// ListFiltersContext is like ListFilters but honors the cancellation and
+// deadline of the given context.
+func (s *Scanner) ListFiltersContext(ctx context.Context, target string) ([]Filter, error) {
+	if target == "" {
+		return nil, fmt.Errorf("target can not be empty, target: %s", target)
+	}
+
+	prefix, err := s.adminPrefix(ctx, target)
+	if err != nil {
+		return nil, err
+	}
+
+	return s.listFilters(ctx, target+prefix+s.endpoints.FilterLoader)
+}
+
+// listFilters gets and parses the filter loader page at URL.
+func (s *Scanner) listFilters(ctx context.Context, URL string) ([]Filter, error) {
+	tin, err := s.quickGet(ctx, PhaseListFilters, URL)
+	if err != nil {
+		return nil, err
+	}
+
+	if tin.status != http.StatusOK {
+		return nil, &ScanError{Kind: ErrUnexpectedStatus, Phase: PhaseListFilters, URL: URL, StatusCode: tin.status}
+	}
+
+	base, err := url.Parse(URL)
+	if err != nil {
+		return nil, err
+	}
+
+	doc, err := html.Parse(strings.NewReader(tin.body))
+	if err != nil {
+		return nil, &ScanError{Kind: ErrFilterLoaderParse, Phase: PhaseListFilters, URL: URL, StatusCode: tin.status, Err: err}
+	}
+
+	filters, err := parseFilterLoader(doc, base)
+	if err != nil {
+		return nil, &ScanError{Kind: ErrFilterLoaderParse, Phase: PhaseListFilters, URL: URL, StatusCode: tin.status, Err: err}
+	}
+
+	return filters, nil
+}
+
+// parseFilterLoader parses the filter loader page, whose URL is base. Every
+// link with the filter_id and revision query parameters, such as the DOWNLOAD
+// ones, identifies a revision of a filter. When the link is in a table row,
+// the details of the revision are read from the cells of the row, named by the
+// header of the table. The links without those parameters are ignored.
+func parseFilterLoader(doc *html.Node, base *url.URL) ([]Filter, error) {
+	var (
+		filters []Filter
+		index   = make(map[string]int)
+	)
+
+	var visit func(n *html.Node) error
+	visit = func(n *html.Node) error {
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if err := visit(c); err != nil {
+				return err
+			}
+		}
+
+		// We are only interested on "a" elements.
+		if n.Type != html.ElementNode || n.Data != "a" {
+			return nil
+		}
+
+		href, ok := attr(n, "href")
+		if !ok {
+			return nil
+		}
+
+		u, err := url.Parse(href)
+		if err != nil {
+			return err
+		}
+
+		q := u.Query()
+
+		// id will contain "origin:Vulncheck:pre" for the case of the vulncheck
+		// filter.
+		id := q.Get("filter_id")
+		if id == "" || q.Get("revision") == "" {
+			return nil
+		}
+		rev, err := strconv.Atoi(q.Get("revision"))
+		if err != nil {
+			return err
+		}
+
+		i, ok := index[id]
+		if !ok {
+			i = len(filters)
+			index[id] = i
+			filters = append(filters, newFilter(id))
+		}
+		f := &filters[i]
+
+		var fr *FilterRevision
+		for j := range f.Revisions {
+			if f.Revisions[j].Revision == rev {
+				fr = &f.Revisions[j]
+				break
+			}
+		}
+		if fr == nil {
+			f.Revisions = append(f.Revisions, FilterRevision{Revision: rev})
+			fr = &f.Revisions[len(f.Revisions)-1]
+		}
+		if strings.EqualFold(q.Get("action"), downloadAction) {
+			fr.Download = base.ResolveReference(u).String()
+		}
+
+		if row := ancestor(n, "tr"); row != nil {
+			readFilterRow(f, fr, row)
+		}
+
+		return nil
+	}
+
+	if err := visit(doc); err != nil {
+		return nil, err
+	}
+
+	for _, f := range filters {
+		sort.Slice(f.Revisions, func(i, j int) bool {
+			return f.Revisions[i].Revision < f.Revisions[j].Revision
+		})
+	}
+
+	return filters, nil
+}
+
+// newFilter returns a filter with the name and type taken from its ID.
+func newFilter(id string) Filter {
+	f := Filter{ID: id}
+	if parts := strings.Split(id, ":"); len(parts) == 3 {
+		f.Name = parts[1]
+		f.Type = parts[2]
+	}
+	return f
+}
+
+// readFilterRow reads the details of a filter revision from the cells of its
+// table row.
+func readFilterRow(f *Filter, fr *FilterRevision, row *html.Node) {
+	table := ancestor(row, "table")
+	if table == nil {
+		return
+	}
+	header := tableHeader(table)
+
+	for i, cell := range cells(row) {
+		if i >= len(header) {
+			break
+		}
+
+		v := strings.TrimSpace(text(cell))
+		if v == "" {
+			continue
+		}
+
+		switch header[i] {
+		case "name":
+			f.Name = v
+		case "type":
+			f.Type = v
+		case "active":
+			fr.Active = parseFlag(v)
+		case "canary":
+			fr.Canary = parseFlag(v)
+		case "order":
+			if order, err := strconv.Atoi(v); err == nil {
+				fr.Order = order
+			}
+		case "created":
+			fr.Created = v
+		}
+	}
+}
+
+// tableHeader returns the normalized names of the columns of the first row
+// with header cells of the table.
+func tableHeader(table *html.Node) []string {
+	var header []string
+
+	var find func(n *html.Node) bool
+	find = func(n *html.Node) bool {
+		if n.Type == html.ElementNode && n.Data == "tr" {
+			cs := cells(n)
+			if len(cs) == 0 || cs[0].Data != "th" {
+				return false
+			}
+			for _, c := range cs {
+				header = append(header, columnName(text(c)))
+			}
+			return true
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			if c.Type == html.ElementNode && c.Data == "table" {
+				continue
+			}
+			if find(c) {
+				return true
+			}
+		}
+		return false
+	}
+	find(table)
+
+	return header
+}
+
+// columnName normalizes the name of a column of the filter loader page.
+func columnName(s string) string {
+	s = strings.ToLower(strings.TrimSpace(s))
+	s = strings.NewReplacer(" ", "", "_", "", "-", "").Replace(s)
+
+	switch s {
+	case "filtername", "name":
+		return "name"
+	case "filtertype", "type":
+		return "type"
+	case "isactive", "active":
+		return "active"
+	case "iscanary", "canary":
+		return "canary"
+	case "filterorder", "order":
+		return "order"
+	case "creationdate", "createdate", "created", "date":
+		return "created"
+	}
+
+	return s
+}
+
+func parseFlag(s string) bool {
+	switch strings.ToLower(s) {
+	case "true", "yes", "1", "active", "canary":
+		return true
+	}
+	return false
+}
+
+// cells returns the cells of a table row.
+func cells(row *html.Node) []*html.Node {
+	var cs []*html.Node
+	for c := row.FirstChild; c != nil; c = c.NextSibling {
+		if c.Type == html.ElementNode && (c.Data == "td" || c.Data == "th") {
+			cs = append(cs, c)
+		}
+	}
+	return cs
+}
+
+// ancestor returns the closest ancestor of the node with the given tag.
+func ancestor(n *html.Node, tag string) *html.Node {
+	for p := n.Parent; p != nil; p = p.Parent {
+		if p.Type == html.ElementNode && p.Data == tag {
+			return p
+		}
+	}
+	return nil
+}
+
+// attr returns the value of an attribute of the node.
+func attr(n *html.Node, key string) (string, bool) {
+	for _, a := range n.Attr {
+		if a.Key == key {
+			return a.Val, true
+		}
+	}
+	return "", false
+}
+
+// text returns the text contents of the node.
+func text(n *html.Node) string {
+	var b strings.Builder
+
+	var collect func(n *html.Node)
+	collect = func(n *html.Node) {
+		if n.Type == html.TextNode {
+			b.WriteString(n.Data)
+		}
+		for c := n.FirstChild; c != nil; c = c.NextSibling {
+			collect(c)
+		}
+	}
+	collect(n)
+
+	return b.String()
+}
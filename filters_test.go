@@ -0,0 +1,137 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package gozuul
+
+import (
+	"errors"
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"testing"
+)
+
+const filterLoaderTable = `<html><body>
+<a href="filterLoader.jsp">Refresh</a>
+<a href="scriptmanager?action=LIST">List</a>
+<table>
+<tr><th>Filter ID</th><th>Revision</th><th>Creation Date</th><th>Is Active</th><th>Is Canary</th><th>Filter Name</th><th>Filter Type</th><th>Filter Order</th><th></th></tr>
+<tr><td>origin:Routing:route</td><td>2</td><td>Mon Mar 04 10:00:00 UTC 2019</td><td>true</td><td>false</td><td>Routing</td><td>route</td><td>10</td><td><a href="scriptmanager?action=DOWNLOAD&filter_id=origin:Routing:route&revision=2">DOWNLOAD</a></td></tr>
+<tr><td>origin:Routing:route</td><td>1</td><td>Fri Mar 01 10:00:00 UTC 2019</td><td>false</td><td>true</td><td>Routing</td><td>route</td><td>10</td><td><a href="scriptmanager?action=DOWNLOAD&filter_id=origin:Routing:route&revision=1">DOWNLOAD</a></td></tr>
+<tr><td>origin:Debug:pre</td><td>1</td><td></td><td>false</td><td>false</td><td>Debug</td><td>pre</td><td></td><td><a href="scriptmanager?action=DOWNLOAD&filter_id=origin:Debug:pre&revision=1">DOWNLOAD</a></td></tr>
+</table>
+</body></html>`
+
+func TestListFilters(t *testing.T) {
+	tests := []struct {
+		name    string
+		body    string
+		want    []Filter
+		wantErr error
+	}{
+		{
+			name: "table",
+			body: filterLoaderTable,
+			want: []Filter{
+				{
+					ID:   "origin:Routing:route",
+					Name: "Routing",
+					Type: "route",
+					Revisions: []FilterRevision{
+						{Revision: 1, Canary: true, Order: 10, Created: "Fri Mar 01 10:00:00 UTC 2019", Download: "/admin/scriptmanager?action=DOWNLOAD&filter_id=origin:Routing:route&revision=1"},
+						{Revision: 2, Active: true, Order: 10, Created: "Mon Mar 04 10:00:00 UTC 2019", Download: "/admin/scriptmanager?action=DOWNLOAD&filter_id=origin:Routing:route&revision=2"},
+					},
+				},
+				{
+					ID:        "origin:Debug:pre",
+					Name:      "Debug",
+					Type:      "pre",
+					Revisions: []FilterRevision{{Revision: 1, Download: "/admin/scriptmanager?action=DOWNLOAD&filter_id=origin:Debug:pre&revision=1"}},
+				},
+			},
+		},
+		{
+			name: "links",
+			body: fmt.Sprintf(vcheckFilter, 1) + fmt.Sprintf(vcheckFilter, 3) + fmt.Sprintf(dummyFilter, 2) + `<a href="filterLoader.jsp">Refresh</a>`,
+			want: []Filter{
+				{
+					ID:   "origin:Vulncheck:pre",
+					Name: "Vulncheck",
+					Type: "pre",
+					Revisions: []FilterRevision{
+						{Revision: 1, Download: "/admin/scriptmanager?action=DOWNLOAD&filter_id=origin:Vulncheck:pre&revision=1"},
+						{Revision: 3, Download: "/admin/scriptmanager?action=DOWNLOAD&filter_id=origin:Vulncheck:pre&revision=3"},
+					},
+				},
+				{
+					ID:        "dummy",
+					Revisions: []FilterRevision{{Revision: 2, Download: "/admin/scriptmanager?action=DOWNLOAD&filter_id=dummy&revision=2"}},
+				},
+			},
+		},
+		{
+			name: "otherActions",
+			body: `<a href="/other/scriptmanager?action=ACTIVATE&filter_id=dummy&revision=1">ACTIVATE</a>`,
+			want: []Filter{
+				{
+					ID:        "dummy",
+					Revisions: []FilterRevision{{Revision: 1}},
+				},
+			},
+		},
+		{
+			name: "empty",
+			body: `<html><body><a href="scriptmanager">Script Manager</a></body></html>`,
+		},
+		{
+			name:    "badRevision",
+			body:    fmt.Sprintf(vcheckFilter, "NaN"),
+			wantErr: ErrFilterLoaderParse,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			mux.HandleFunc("/", notFound)
+			mux.HandleFunc("/admin/filterLoader.jsp", func(w http.ResponseWriter, r *http.Request) {
+				fmt.Fprint(w, tt.body)
+			})
+			ts := httptest.NewServer(mux)
+			defer ts.Close()
+
+			got, err := ListFilters(ts.URL)
+			if tt.wantErr != nil {
+				if !errors.Is(err, tt.wantErr) {
+					t.Fatalf("error expected: %v, got: %v", tt.wantErr, err)
+				}
+				return
+			}
+			if err != nil {
+				t.Fatalf("nil error expected, got %v", err)
+			}
+			// The download links are resolved against the URL of the server.
+			for _, f := range tt.want {
+				for i := range f.Revisions {
+					if f.Revisions[i].Download != "" {
+						f.Revisions[i].Download = ts.URL + f.Revisions[i].Download
+					}
+				}
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("filters expected:\n%+v\ngot:\n%+v", tt.want, got)
+			}
+		})
+	}
+}
+
+func TestListFiltersStatus(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(notFound))
+	defer ts.Close()
+
+	if _, err := ListFilters(ts.URL); !errors.Is(err, ErrUnexpectedStatus) {
+		t.Errorf("error expected: %v, got: %v", ErrUnexpectedStatus, err)
+	}
+}
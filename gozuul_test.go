@@ -5,10 +5,13 @@ Copyright 2019 Adevinta
 package gozuul
 
 import (
+	"context"
+	"errors"
 	"fmt"
 	"net/http"
 	"net/http/httptest"
 	"testing"
+	"time"
 
 	"github.com/julienschmidt/httprouter"
 )
@@ -43,6 +46,7 @@ var testCasesPScan = []struct {
 	adminDisabled    bool
 	vulnerable       bool
 	mightVulnerable  bool
+	verdict          Verdict
 }{
 	{
 		name:            "forbidden",
@@ -52,6 +56,7 @@ var testCasesPScan = []struct {
 		adminDisabled:   true,
 		vulnerable:      false,
 		mightVulnerable: false,
+		verdict:         VerdictAdminDisabled,
 	}, {
 		name:            "vulnerable",
 		f:               vulnerable,
@@ -60,6 +65,7 @@ var testCasesPScan = []struct {
 		adminDisabled:   false,
 		vulnerable:      true,
 		mightVulnerable: false,
+		verdict:         VerdictVulnerable,
 	}, {
 		name:            "notFound",
 		f:               notFound,
@@ -68,6 +74,7 @@ var testCasesPScan = []struct {
 		adminDisabled:   false,
 		vulnerable:      false,
 		mightVulnerable: false,
+		verdict:         VerdictNotZuul,
 	}, {
 		name:            "internalServerError",
 		f:               internalServerError,
@@ -76,6 +83,7 @@ var testCasesPScan = []struct {
 		adminDisabled:   false,
 		vulnerable:      false,
 		mightVulnerable: false,
+		verdict:         VerdictInconclusive,
 	}, {
 		name:            "badRequest",
 		f:               badRequest,
@@ -84,6 +92,7 @@ var testCasesPScan = []struct {
 		adminDisabled:   false,
 		vulnerable:      false,
 		mightVulnerable: false,
+		verdict:         VerdictInconclusive,
 	}, {
 		name:            "statusOK",
 		f:               ok,
@@ -92,6 +101,7 @@ var testCasesPScan = []struct {
 		adminDisabled:   false,
 		vulnerable:      false,
 		mightVulnerable: false,
+		verdict:         VerdictInconclusive,
 	},
 }
 
@@ -170,6 +180,9 @@ func TestPassiveScan(t *testing.T) {
 			if tc.mightVulnerable != rs.MightVulnerable {
 				t.Errorf("(%v) mightVulnerable expected: %v, got: %v", tc.name, tc.mightVulnerable, rs.MightVulnerable)
 			}
+			if tc.verdict != rs.Verdict {
+				t.Errorf("(%v) verdict expected: %v, got: %v (%v)", tc.name, tc.verdict, rs.Verdict, rs.Reason)
+			}
 		})
 	}
 }
@@ -225,6 +238,8 @@ var testCasesAScan = []struct {
 	adminDisabled    bool
 	vulnerable       bool
 	mightVulnerable  bool
+	verdict          Verdict
+	errKind          error
 }{
 	{
 		name: "vulnFilterEnabled",
@@ -240,6 +255,7 @@ var testCasesAScan = []struct {
 		adminDisabled:   false,
 		vulnerable:      false,
 		mightVulnerable: false,
+		verdict:         VerdictVulnerable,
 		callbackRec:     false,
 	}, {
 		name: "vulnerable",
@@ -265,6 +281,7 @@ var testCasesAScan = []struct {
 		adminDisabled:   false,
 		vulnerable:      true,
 		mightVulnerable: false,
+		verdict:         VerdictVulnerable,
 		callbackRec:     false,
 	}, {
 		name: "mightVulnerable",
@@ -290,6 +307,7 @@ var testCasesAScan = []struct {
 		adminDisabled:   false,
 		vulnerable:      false,
 		mightVulnerable: true,
+		verdict:         VerdictPossiblyVulnerable,
 		callbackRec:     false,
 	}, {
 		name: "adminDisabled",
@@ -315,6 +333,7 @@ var testCasesAScan = []struct {
 		adminDisabled:   true,
 		vulnerable:      false,
 		mightVulnerable: false,
+		verdict:         VerdictAdminDisabled,
 		callbackRec:     false,
 	}, {
 		name: "callbackReceived",
@@ -340,6 +359,7 @@ var testCasesAScan = []struct {
 		adminDisabled:   false,
 		vulnerable:      true,
 		mightVulnerable: false,
+		verdict:         VerdictVulnerable,
 		callbackRec:     true,
 	}, {
 		name: "filterEndpointNotExists",
@@ -360,6 +380,8 @@ var testCasesAScan = []struct {
 		adminDisabled:   false,
 		vulnerable:      false,
 		mightVulnerable: false,
+		verdict:         VerdictInconclusive,
+		errKind:         ErrUnexpectedStatus,
 		callbackRec:     false,
 	}, {
 		name: "badFilterHref",
@@ -380,6 +402,8 @@ var testCasesAScan = []struct {
 		adminDisabled:   false,
 		vulnerable:      false,
 		mightVulnerable: false,
+		verdict:         VerdictInconclusive,
+		errKind:         ErrFilterLoaderParse,
 		callbackRec:     false,
 	}, {
 		name: "badFilterRev",
@@ -400,6 +424,8 @@ var testCasesAScan = []struct {
 		adminDisabled:   false,
 		vulnerable:      false,
 		mightVulnerable: false,
+		verdict:         VerdictInconclusive,
+		errKind:         ErrFilterLoaderParse,
 		callbackRec:     false,
 	}, {
 		name: "filterDoesNotEnable",
@@ -426,6 +452,8 @@ var testCasesAScan = []struct {
 		adminDisabled:   false,
 		vulnerable:      false,
 		mightVulnerable: false,
+		verdict:         VerdictInconclusive,
+		errKind:         ErrActivationTimeout,
 		callbackRec:     false,
 	}, {
 		name: "revisionNotUpdated",
@@ -451,6 +479,8 @@ var testCasesAScan = []struct {
 		adminDisabled:   false,
 		vulnerable:      false,
 		mightVulnerable: false,
+		verdict:         VerdictInconclusive,
+		errKind:         ErrRevisionNotIncreased,
 		callbackRec:     false,
 	}, {
 		name: "errorSettingFilter",
@@ -476,6 +506,35 @@ var testCasesAScan = []struct {
 		adminDisabled:   false,
 		vulnerable:      false,
 		mightVulnerable: false,
+		verdict:         VerdictInconclusive,
+		errKind:         ErrUnexpectedStatus,
+		callbackRec:     false,
+	}, {
+		name: "errorDeactivatingFilter",
+		funcs: []route{
+			route{
+				path:    vcheckEndpoint,
+				method:  "GET",
+				handler: toggleFilterEnabled, // Toggle from not enabled to enabled.
+			},
+			route{
+				path:    filtersEndpoint,
+				method:  "GET",
+				handler: incrementingFilters, // Revision of filters increment at each call.
+			},
+			route{
+				path:    "/admin/scriptmanager",
+				method:  "POST",
+				handler: badFilterDeactivate, // Filter upload and activation succeed, deactivation fails.
+			},
+		},
+		nilError:        false,
+		prevEnabled:     false,
+		adminDisabled:   false,
+		vulnerable:      true,
+		mightVulnerable: false,
+		verdict:         VerdictVulnerable,
+		errKind:         ErrDeactivation,
 		callbackRec:     false,
 	},
 }
@@ -552,6 +611,15 @@ func badFilterUpdate(w http.ResponseWriter, r *http.Request, ps httprouter.Param
 	http.Error(w, "", 500)
 }
 
+func badFilterDeactivate(w http.ResponseWriter, r *http.Request, ps httprouter.Params) {
+	if r.FormValue("action") == "DEACTIVATE" {
+		http.Error(w, "", 500)
+		return
+	}
+	w.Header().Set("Location", "http://donotfollow.example.com")
+	http.Error(w, "", 302)
+}
+
 func TestActiveScan(t *testing.T) {
 	// Test all the test cases defined in testCasesArgsAScan
 	for _, tc := range testCasesArgsAScan {
@@ -609,6 +677,41 @@ func TestActiveScan(t *testing.T) {
 			if tc.mightVulnerable != rs.MightVulnerable {
 				t.Errorf("(%v) mightVulnerable expected: %v, got: %v", tc.name, tc.mightVulnerable, rs.MightVulnerable)
 			}
+			if tc.verdict != rs.Verdict {
+				t.Errorf("(%v) verdict expected: %v, got: %v (%v)", tc.name, tc.verdict, rs.Verdict, rs.Reason)
+			}
+			if tc.errKind != nil && !errors.Is(err, tc.errKind) {
+				t.Errorf("(%v) error kind expected: %v, got error: %v", tc.name, tc.errKind, err)
+			}
 		})
 	}
 }
+
+func TestScanContextCancellation(t *testing.T) {
+	clearGlobals()
+
+	mux := httprouter.New()
+	mux.Handle("GET", vcheckEndpoint, adaptHandler(notFound))       // Filter never gets enabled.
+	mux.Handle("GET", filtersEndpoint, incrementingFilters)         // Revision of filters increment at each call.
+	mux.Handle("POST", "/admin/scriptmanager", adaptHandler(found)) // Filter upload and setFilter succeed.
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 500*time.Millisecond)
+	defer cancel()
+
+	start := time.Now()
+	_, err := ActiveScanContext(ctx, ts.URL, "", make(chan bool, 1))
+	if !errors.Is(err, context.DeadlineExceeded) {
+		t.Errorf("context.DeadlineExceeded expected, got: %v", err)
+	}
+	if elapsed := time.Since(start); elapsed > 5*time.Second {
+		t.Errorf("scan not cancelled in time, elapsed: %v", elapsed)
+	}
+
+	cctx, ccancel := context.WithCancel(context.Background())
+	ccancel()
+	if _, err := PassiveScanContext(cctx, ts.URL); !errors.Is(err, context.Canceled) {
+		t.Errorf("context.Canceled expected, got: %v", err)
+	}
+}
@@ -0,0 +1,82 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package gozuul
+
+import "sort"
+
+// Inventory maps the IDs of the filters of a target to their latest
+// revision.
+type Inventory map[string]int
+
+// NewInventory returns the inventory of the given filters.
+func NewInventory(filters []Filter) Inventory {
+	inv := make(Inventory)
+	for _, f := range filters {
+		inv[f.ID] = f.Latest()
+	}
+	return inv
+}
+
+// InventoryEntry is a filter of an inventory.
+type InventoryEntry struct {
+	ID       string `json:"id"`
+	Revision int    `json:"revision"`
+}
+
+// RevisionBump is a filter whose latest revision differs between two
+// inventories.
+type RevisionBump struct {
+	ID       string `json:"id"`
+	Baseline int    `json:"baseline"`
+	Live     int    `json:"live"`
+}
+
+// Drift contains the differences between a baseline inventory and a live
+// one. Added are the filters only in the live inventory, Removed the ones
+// only in the baseline, and Bumped the ones with a different latest revision.
+// Every list is sorted by ID.
+type Drift struct {
+	Added   []InventoryEntry `json:"added"`
+	Removed []InventoryEntry `json:"removed"`
+	Bumped  []RevisionBump   `json:"bumped"`
+}
+
+// Empty reports whether there are no differences.
+func (d Drift) Empty() bool {
+	return len(d.Added) == 0 && len(d.Removed) == 0 && len(d.Bumped) == 0
+}
+
+// CompareInventories returns the differences between the baseline and the
+// live inventories.
+func CompareInventories(baseline, live Inventory) Drift {
+	d := Drift{Added: []InventoryEntry{}, Removed: []InventoryEntry{}, Bumped: []RevisionBump{}}
+
+	for _, id := range sortedIDs(live) {
+		rev, ok := baseline[id]
+		switch {
+		case !ok:
+			d.Added = append(d.Added, InventoryEntry{ID: id, Revision: live[id]})
+		case rev != live[id]:
+			d.Bumped = append(d.Bumped, RevisionBump{ID: id, Baseline: rev, Live: live[id]})
+		}
+	}
+
+	for _, id := range sortedIDs(baseline) {
+		if _, ok := live[id]; !ok {
+			d.Removed = append(d.Removed, InventoryEntry{ID: id, Revision: baseline[id]})
+		}
+	}
+
+	return d
+}
+
+func sortedIDs(inv Inventory) []string {
+	ids := make([]string, 0, len(inv))
+	for id := range inv {
+		ids = append(ids, id)
+	}
+	sort.Strings(ids)
+	return ids
+}
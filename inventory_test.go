@@ -0,0 +1,60 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package gozuul
+
+import (
+	"reflect"
+	"testing"
+)
+
+func TestNewInventory(t *testing.T) {
+	filters := []Filter{
+		{ID: "origin:Routing:route", Revisions: []FilterRevision{{Revision: 1}, {Revision: 3}, {Revision: 2}}},
+		{ID: "origin:Debug:pre", Revisions: []FilterRevision{{Revision: 1}}},
+	}
+
+	want := Inventory{"origin:Routing:route": 3, "origin:Debug:pre": 1}
+	if got := NewInventory(filters); !reflect.DeepEqual(got, want) {
+		t.Errorf("inventory expected: %v, got: %v", want, got)
+	}
+}
+
+func TestCompareInventories(t *testing.T) {
+	tests := []struct {
+		name     string
+		baseline Inventory
+		live     Inventory
+		want     Drift
+	}{
+		{
+			name:     "same",
+			baseline: Inventory{"origin:Routing:route": 2, "origin:Debug:pre": 1},
+			live:     Inventory{"origin:Routing:route": 2, "origin:Debug:pre": 1},
+			want:     Drift{Added: []InventoryEntry{}, Removed: []InventoryEntry{}, Bumped: []RevisionBump{}},
+		},
+		{
+			name:     "drift",
+			baseline: Inventory{"origin:Routing:route": 2, "origin:Debug:pre": 1, "origin:Stats:post": 4},
+			live:     Inventory{"origin:Routing:route": 3, "origin:Vulncheck:pre": 1, "origin:Backdoor:pre": 2, "origin:Stats:post": 4},
+			want: Drift{
+				Added:   []InventoryEntry{{"origin:Backdoor:pre", 2}, {"origin:Vulncheck:pre", 1}},
+				Removed: []InventoryEntry{{"origin:Debug:pre", 1}},
+				Bumped:  []RevisionBump{{"origin:Routing:route", 2, 3}},
+			},
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got := CompareInventories(tt.baseline, tt.live)
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("drift expected: %+v, got: %+v", tt.want, got)
+			}
+			if got.Empty() != (tt.name == "same") {
+				t.Errorf("unexpected Empty: %v", got.Empty())
+			}
+		})
+	}
+}
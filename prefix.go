@@ -0,0 +1,56 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package gozuul
+
+import (
+	"context"
+	"net/http"
+	"strings"
+)
+
+// normalizeContextPath returns the context path with a leading slash and
+// without a trailing one. The root of the target is the empty path.
+func normalizeContextPath(p string) string {
+	p = strings.Trim(strings.TrimSpace(p), "/")
+	if p == "" {
+		return ""
+	}
+	return "/" + p
+}
+
+// adminPrefix returns the context path under which the admin servlets of the
+// target live. Without candidate context paths, they are expected at the
+// root of the target. Otherwise, the script manager of every candidate is
+// requested without an action: the script manager answers with its usage
+// banner, unless its access is forbidden or requires authentication. The
+// first candidate with the banner is preferred over the first protected one,
+// and the root of the target is used when none of them matches.
+func (s *Scanner) adminPrefix(ctx context.Context, target string) (string, error) {
+	if len(s.contextPaths) == 0 {
+		return "", nil
+	}
+
+	protected := -1
+	for i, p := range s.contextPaths {
+		tin, err := s.quickGet(ctx, PhaseDiscoverPrefix, target+p+s.endpoints.ScriptManager)
+		if err != nil {
+			return "", err
+		}
+
+		if strings.Contains(tin.body, vulnerableDork) {
+			return p, nil
+		}
+
+		if protected < 0 && (tin.status == http.StatusForbidden || tin.status == http.StatusUnauthorized) {
+			protected = i
+		}
+	}
+
+	if protected >= 0 {
+		return s.contextPaths[protected], nil
+	}
+
+	return "", nil
+}
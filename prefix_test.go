@@ -0,0 +1,73 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package gozuul
+
+import (
+	"net/http"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestContextPaths(t *testing.T) {
+	tests := []struct {
+		name       string
+		paths      []string
+		handlers   map[string]http.HandlerFunc
+		wantPrefix string
+		verdict    Verdict
+	}{
+		{
+			name:  "banner",
+			paths: []string{"/", "zuul/", "/gateway"},
+			handlers: map[string]http.HandlerFunc{
+				"/zuul/admin/scriptmanager":    forbidden,
+				"/gateway/admin/scriptmanager": vulnerable,
+			},
+			wantPrefix: "/gateway",
+			verdict:    VerdictVulnerable,
+		},
+		{
+			name:  "protected",
+			paths: []string{"", "/zuul", "/gateway"},
+			handlers: map[string]http.HandlerFunc{
+				"/zuul/admin/scriptmanager": forbidden,
+			},
+			wantPrefix: "/zuul",
+			verdict:    VerdictAdminDisabled,
+		},
+		{
+			name:       "notFound",
+			paths:      []string{"/gateway", ""},
+			handlers:   map[string]http.HandlerFunc{},
+			wantPrefix: "",
+			verdict:    VerdictNotZuul,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			mux := http.NewServeMux()
+			mux.HandleFunc("/", notFound)
+			for path, h := range tt.handlers {
+				mux.HandleFunc(path, h)
+			}
+			ts := httptest.NewServer(mux)
+			defer ts.Close()
+
+			s := NewScanner(WithContextPaths(tt.paths...))
+
+			rs, err := s.PassiveScan(ts.URL)
+			if err != nil {
+				t.Fatalf("nil error expected, got %v", err)
+			}
+			if rs.Prefix != tt.wantPrefix {
+				t.Errorf("prefix expected: %q, got: %q", tt.wantPrefix, rs.Prefix)
+			}
+			if rs.Verdict != tt.verdict {
+				t.Errorf("verdict expected: %v, got: %v", tt.verdict, rs.Verdict)
+			}
+		})
+	}
+}
@@ -16,6 +16,7 @@ import (
 
 var files = []string{
 	"Vulncheck.groovy",
+	"VulncheckDNS.groovy",
 }
 
 func main() {
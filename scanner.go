@@ -0,0 +1,173 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package gozuul
+
+import (
+	"crypto/tls"
+	"net/http"
+	"time"
+)
+
+const defaultTimeout = 5 * time.Second
+
+// Scanner executes passive and active scans using its own HTTP client.
+// A Scanner is safe for concurrent use by multiple goroutines and should be
+// created with NewScanner.
+type Scanner struct {
+	timeout       time.Duration
+	transport     http.RoundTripper
+	tlsConfig     *tls.Config
+	userAgent     string
+	checkRedirect func(req *http.Request, via []*http.Request) error
+	evidence      *EvidenceConfig
+	contextPaths  []string
+	endpoints     Endpoints
+
+	client *http.Client
+}
+
+// Option configures a Scanner.
+type Option func(*Scanner)
+
+// WithTimeout sets the timeout of every HTTP request made by the Scanner.
+// The default is 5 seconds.
+func WithTimeout(timeout time.Duration) Option {
+	return func(s *Scanner) {
+		s.timeout = timeout
+	}
+}
+
+// WithTransport sets the http.RoundTripper used by the Scanner. When set, the
+// TLS configuration passed with WithTLSConfig is ignored and must be
+// configured in the RoundTripper itself.
+func WithTransport(rt http.RoundTripper) Option {
+	return func(s *Scanner) {
+		s.transport = rt
+	}
+}
+
+// WithTLSConfig sets the TLS configuration of the default transport. The
+// default configuration skips the verification of the server certificates.
+func WithTLSConfig(c *tls.Config) Option {
+	return func(s *Scanner) {
+		s.tlsConfig = c
+	}
+}
+
+// WithUserAgent sets the User-Agent header sent in every HTTP request made by
+// the Scanner.
+func WithUserAgent(ua string) Option {
+	return func(s *Scanner) {
+		s.userAgent = ua
+	}
+}
+
+// WithCheckRedirect sets the redirect policy of the Scanner, with the same
+// semantics as the CheckRedirect field of http.Client. By default redirects
+// are not followed, as the scans rely on the redirect responses themselves.
+func WithCheckRedirect(f func(req *http.Request, via []*http.Request) error) Option {
+	return func(s *Scanner) {
+		s.checkRedirect = f
+	}
+}
+
+// WithContextPaths sets the candidate context paths under which the admin
+// servlets of the targets might be deployed, for instance "/gateway". Before
+// every scan, the candidates are probed to find the one where the admin
+// servlets live, which is used as the prefix of the admin endpoints and
+// reported in ResultSet.Prefix. The root of the target is probed only if it
+// is one of the candidates, given as "" or "/". By default the admin
+// endpoints are expected at the root of the targets and nothing is probed.
+func WithContextPaths(paths ...string) Option {
+	return func(s *Scanner) {
+		s.contextPaths = nil
+		for _, p := range paths {
+			s.contextPaths = append(s.contextPaths, normalizeContextPath(p))
+		}
+	}
+}
+
+// NewScanner returns a new Scanner configured with the given options.
+func NewScanner(opts ...Option) *Scanner {
+	s := &Scanner{
+		timeout:   defaultTimeout,
+		tlsConfig: &tls.Config{InsecureSkipVerify: true},
+		endpoints: DefaultEndpoints(),
+		checkRedirect: func(req *http.Request, via []*http.Request) error {
+			return http.ErrUseLastResponse
+		},
+	}
+
+	for _, opt := range opts {
+		opt(s)
+	}
+
+	tr := s.transport
+	if tr == nil {
+		tr = &http.Transport{TLSClientConfig: s.tlsConfig}
+	}
+
+	s.client = &http.Client{
+		CheckRedirect: s.checkRedirect,
+		Transport:     tr,
+		Timeout:       s.timeout,
+	}
+
+	return s
+}
+
+// defaultScanner is the Scanner used by the package level scan functions.
+var defaultScanner = NewScanner()
+
+// Client returns an HTTP client that sends the requests as the Scanner does,
+// with its transport, timeout, redirect policy and user agent, so the other
+// requests made to the targets, such as probes, honor its options.
+func (s *Scanner) Client() *http.Client {
+	c := *s.client
+	if s.userAgent != "" {
+		c.Transport = userAgentTransport{rt: c.Transport, userAgent: s.userAgent}
+	}
+	return &c
+}
+
+// userAgentTransport sets the User-Agent header of the requests sent through
+// rt.
+type userAgentTransport struct {
+	rt        http.RoundTripper
+	userAgent string
+}
+
+func (t userAgentTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	req = req.Clone(req.Context())
+	req.Header.Set("User-Agent", t.userAgent)
+	return t.rt.RoundTrip(req)
+}
+
+// do sends an HTTP request using the client of the Scanner. Failed requests
+// are reported as a *ScanError of kind ErrNetwork for the given phase.
+func (s *Scanner) do(req *http.Request, phase Phase) (*http.Response, error) {
+	if s.userAgent != "" {
+		req.Header.Set("User-Agent", s.userAgent)
+	}
+
+	res, err := s.client.Do(req)
+	s.record(req, res, err)
+	if err != nil {
+		return nil, s.requestError(req, phase, err)
+	}
+
+	return res, nil
+}
+
+// requestError wraps an error that happened while sending a request or
+// reading its response. Errors caused by the cancellation of the request
+// context are returned as is.
+func (s *Scanner) requestError(req *http.Request, phase Phase, err error) error {
+	if req.Context().Err() != nil {
+		return err
+	}
+
+	return &ScanError{Kind: ErrNetwork, Phase: phase, URL: req.URL.String(), Err: err}
+}
@@ -0,0 +1,147 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package gozuul
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+)
+
+type countingTransport struct {
+	calls int
+}
+
+func (ct *countingTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	ct.calls++
+	return http.DefaultTransport.RoundTrip(req)
+}
+
+func TestScannerOptions(t *testing.T) {
+	var ua string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ua = r.UserAgent()
+		vulnerable(w, r)
+	}))
+	defer ts.Close()
+
+	ct := &countingTransport{}
+	s := NewScanner(
+		WithTransport(ct),
+		WithUserAgent("gozuul-test"),
+		WithTimeout(time.Second),
+	)
+
+	rs, err := s.PassiveScan(ts.URL)
+	if err != nil {
+		t.Fatalf("nil error expected, got %v", err)
+	}
+	if !rs.Vulnerable {
+		t.Errorf("vulnerable expected: true, got: %v", rs.Vulnerable)
+	}
+	if ct.calls != 1 {
+		t.Errorf("transport calls expected: 1, got: %v", ct.calls)
+	}
+	if ua != "gozuul-test" {
+		t.Errorf("user agent expected: %q, got: %q", "gozuul-test", ua)
+	}
+}
+
+func TestScannerTLSConfig(t *testing.T) {
+	ts := httptest.NewUnstartedServer(http.HandlerFunc(vulnerable))
+	ts.Config.ErrorLog = log.New(ioutil.Discard, "", 0)
+	ts.StartTLS()
+	defer ts.Close()
+
+	// The certificate of the test server is not trusted by a strict
+	// configuration.
+	_, err := NewScanner(WithTLSConfig(&tls.Config{})).PassiveScan(ts.URL)
+	if !errors.Is(err, ErrNetwork) {
+		t.Fatalf("error expected: %v, got %v", ErrNetwork, err)
+	}
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ts.Certificate())
+	rs, err := NewScanner(WithTLSConfig(&tls.Config{RootCAs: roots})).PassiveScan(ts.URL)
+	if err != nil {
+		t.Fatalf("nil error expected, got %v", err)
+	}
+	if !rs.Vulnerable {
+		t.Errorf("vulnerable expected: true, got: %v", rs.Vulnerable)
+	}
+}
+
+func TestScannerCheckRedirect(t *testing.T) {
+	mux := http.NewServeMux()
+	mux.HandleFunc("/", notFound)
+	mux.HandleFunc("/admin/scriptmanager", func(w http.ResponseWriter, r *http.Request) {
+		http.Redirect(w, r, "/moved/scriptmanager", http.StatusFound)
+	})
+	mux.HandleFunc("/moved/scriptmanager", vulnerable)
+	ts := httptest.NewServer(mux)
+	defer ts.Close()
+
+	// Redirects are not followed by default.
+	rs, err := NewScanner().PassiveScan(ts.URL)
+	if err != nil {
+		t.Fatalf("nil error expected, got %v", err)
+	}
+	if rs.Vulnerable {
+		t.Errorf("vulnerable expected: false, got: %v", rs.Vulnerable)
+	}
+
+	var redirects []string
+	s := NewScanner(WithCheckRedirect(func(req *http.Request, via []*http.Request) error {
+		redirects = append(redirects, req.URL.Path)
+		return nil
+	}))
+	rs, err = s.PassiveScan(ts.URL)
+	if err != nil {
+		t.Fatalf("nil error expected, got %v", err)
+	}
+	if !rs.Vulnerable {
+		t.Errorf("vulnerable expected: true, got: %v", rs.Vulnerable)
+	}
+	if len(redirects) != 1 || redirects[0] != "/moved/scriptmanager" {
+		t.Errorf("redirect expected: /moved/scriptmanager, got: %v", redirects)
+	}
+}
+
+func TestScannerClient(t *testing.T) {
+	var ua string
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		ua = r.UserAgent()
+		http.Redirect(w, r, "/moved", http.StatusFound)
+	}))
+	defer ts.Close()
+
+	ct := &countingTransport{}
+	c := NewScanner(WithTransport(ct), WithUserAgent("gozuul-test"), WithTimeout(time.Second)).Client()
+	if c.Timeout != time.Second {
+		t.Errorf("timeout expected: %v, got: %v", time.Second, c.Timeout)
+	}
+
+	res, err := c.Get(ts.URL)
+	if err != nil {
+		t.Fatalf("nil error expected, got %v", err)
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusFound {
+		t.Errorf("redirect not followed expected, got status: %v", res.StatusCode)
+	}
+	if ct.calls != 1 {
+		t.Errorf("transport calls expected: 1, got: %v", ct.calls)
+	}
+	if ua != "gozuul-test" {
+		t.Errorf("user agent expected: %q, got: %q", "gozuul-test", ua)
+	}
+}
@@ -0,0 +1,248 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package targets
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/xml"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/url"
+	"strconv"
+	"strings"
+)
+
+// Format is the format of a file listing targets.
+type Format string
+
+const (
+	// FormatAuto detects the format from the contents of the file.
+	FormatAuto Format = "auto"
+	// FormatList is a target specification per line.
+	FormatList Format = "list"
+	// FormatNmap is an nmap XML report, whose open HTTP and HTTPS services
+	// are the targets.
+	FormatNmap Format = "nmap"
+	// FormatHAR is an HTTP Archive, whose requested sites are the targets.
+	FormatHAR Format = "har"
+	// FormatBurp is a Burp Suite XML export, whose requested sites are the
+	// targets.
+	FormatBurp Format = "burp"
+)
+
+// DetectFormat returns the format of the contents of a file. Contents not
+// recognized as nmap, HAR or Burp are considered a list.
+func DetectFormat(b []byte) Format {
+	b = bytes.TrimSpace(b)
+
+	switch {
+	case bytes.HasPrefix(b, []byte("{")):
+		return FormatHAR
+	case bytes.HasPrefix(b, []byte("<")) && bytes.Contains(b, []byte("<nmaprun")):
+		return FormatNmap
+	case bytes.HasPrefix(b, []byte("<")) && bytes.Contains(b, []byte("<items")):
+		return FormatBurp
+	}
+
+	return FormatList
+}
+
+// Read reads the target specifications from a file in the given format. The
+// targets imported from nmap, HAR and Burp files are deduplicated base URLs.
+func Read(r io.Reader, format Format) ([]string, error) {
+	b, err := ioutil.ReadAll(r)
+	if err != nil {
+		return nil, err
+	}
+
+	if format == FormatAuto || format == "" {
+		format = DetectFormat(b)
+	}
+
+	var urls []string
+	switch format {
+	case FormatList:
+		return ReadSpecs(bytes.NewReader(b))
+	case FormatNmap:
+		urls, err = parseNmap(b)
+	case FormatHAR:
+		urls, err = parseHAR(b)
+	case FormatBurp:
+		urls, err = parseBurp(b)
+	default:
+		return nil, fmt.Errorf("unknown targets format: %v", format)
+	}
+	if err != nil {
+		return nil, fmt.Errorf("unable to read %v targets: %w", format, err)
+	}
+
+	return Dedup(urls), nil
+}
+
+type nmapRun struct {
+	Hosts []struct {
+		Addresses []struct {
+			Addr     string `xml:"addr,attr"`
+			AddrType string `xml:"addrtype,attr"`
+		} `xml:"address"`
+		Hostnames []struct {
+			Name string `xml:"name,attr"`
+			Type string `xml:"type,attr"`
+		} `xml:"hostnames>hostname"`
+		Ports []struct {
+			Protocol string `xml:"protocol,attr"`
+			PortID   int    `xml:"portid,attr"`
+			State    struct {
+				State string `xml:"state,attr"`
+			} `xml:"state"`
+			Service struct {
+				Name   string `xml:"name,attr"`
+				Tunnel string `xml:"tunnel,attr"`
+			} `xml:"service"`
+		} `xml:"ports>port"`
+	} `xml:"host"`
+}
+
+// parseNmap returns the base URLs of the open HTTP and HTTPS services of an
+// nmap XML report. The hosts are named as they were specified to nmap, or by
+// their IP address otherwise.
+func parseNmap(b []byte) ([]string, error) {
+	var run nmapRun
+	if err := xml.Unmarshal(b, &run); err != nil {
+		return nil, err
+	}
+
+	var urls []string
+	for _, h := range run.Hosts {
+		host := ""
+		for _, a := range h.Addresses {
+			if a.AddrType == "ipv4" || a.AddrType == "ipv6" {
+				host = a.Addr
+				break
+			}
+		}
+		for _, hn := range h.Hostnames {
+			if hn.Type == "user" {
+				host = hn.Name
+				break
+			}
+		}
+		if host == "" {
+			continue
+		}
+
+		for _, p := range h.Ports {
+			if p.Protocol != "tcp" || p.State.State != "open" || !strings.HasPrefix(p.Service.Name, "http") {
+				continue
+			}
+
+			scheme := "http"
+			if p.Service.Tunnel == "ssl" || strings.HasPrefix(p.Service.Name, "https") {
+				scheme = "https"
+			}
+			urls = append(urls, BaseURL(scheme, host, p.PortID))
+		}
+	}
+
+	return urls, nil
+}
+
+type harLog struct {
+	Log struct {
+		Entries []struct {
+			Request struct {
+				URL string `json:"url"`
+			} `json:"request"`
+		} `json:"entries"`
+	} `json:"log"`
+}
+
+// parseHAR returns the base URLs of the requests of an HTTP Archive.
+func parseHAR(b []byte) ([]string, error) {
+	var har harLog
+	if err := json.Unmarshal(b, &har); err != nil {
+		return nil, err
+	}
+
+	var urls []string
+	for _, e := range har.Log.Entries {
+		u, err := siteURL(e.Request.URL)
+		if err != nil {
+			return nil, err
+		}
+		if u != "" {
+			urls = append(urls, u)
+		}
+	}
+
+	return urls, nil
+}
+
+type burpItems struct {
+	Items []struct {
+		URL      string `xml:"url"`
+		Host     string `xml:"host"`
+		Port     int    `xml:"port"`
+		Protocol string `xml:"protocol"`
+	} `xml:"item"`
+}
+
+// parseBurp returns the base URLs of the requests of a Burp Suite XML export.
+func parseBurp(b []byte) ([]string, error) {
+	var items burpItems
+
+	dec := xml.NewDecoder(bytes.NewReader(b))
+	dec.Strict = false
+	if err := dec.Decode(&items); err != nil {
+		return nil, err
+	}
+
+	var urls []string
+	for _, it := range items.Items {
+		if it.Host != "" && it.Port != 0 && (it.Protocol == "http" || it.Protocol == "https") {
+			urls = append(urls, BaseURL(it.Protocol, it.Host, it.Port))
+			continue
+		}
+
+		u, err := siteURL(it.URL)
+		if err != nil {
+			return nil, err
+		}
+		if u != "" {
+			urls = append(urls, u)
+		}
+	}
+
+	return urls, nil
+}
+
+// siteURL returns the base URL of the site of an http or https URL, or an
+// empty string for the URLs with other schemes.
+func siteURL(rawURL string) (string, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return "", err
+	}
+
+	var port int
+	switch u.Scheme {
+	case "http":
+		port = 80
+	case "https":
+		port = 443
+	default:
+		return "", nil
+	}
+
+	if p := u.Port(); p != "" {
+		if port, err = strconv.Atoi(p); err != nil {
+			return "", fmt.Errorf("invalid port in %q", rawURL)
+		}
+	}
+
+	return BaseURL(u.Scheme, u.Hostname(), port), nil
+}
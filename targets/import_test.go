@@ -0,0 +1,141 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package targets
+
+import (
+	"reflect"
+	"strings"
+	"testing"
+)
+
+const nmapReport = `<?xml version="1.0" encoding="UTF-8"?>
+<!DOCTYPE nmaprun>
+<nmaprun scanner="nmap" args="nmap -sV gateway.example.com 10.0.0.2">
+<host>
+<status state="up"/>
+<address addr="10.0.0.1" addrtype="ipv4"/>
+<address addr="00:11:22:33:44:55" addrtype="mac"/>
+<hostnames><hostname name="gateway.example.com" type="user"/><hostname name="ip-10-0-0-1" type="PTR"/></hostnames>
+<ports>
+<port protocol="tcp" portid="22"><state state="open"/><service name="ssh"/></port>
+<port protocol="tcp" portid="80"><state state="open"/><service name="http"/></port>
+<port protocol="tcp" portid="443"><state state="open"/><service name="http" tunnel="ssl"/></port>
+<port protocol="tcp" portid="8080"><state state="closed"/><service name="http-proxy"/></port>
+</ports>
+</host>
+<host>
+<status state="up"/>
+<address addr="10.0.0.2" addrtype="ipv4"/>
+<ports>
+<port protocol="tcp" portid="8443"><state state="open"/><service name="https-alt"/></port>
+<port protocol="tcp" portid="8080"><state state="open"/><service name="http-proxy"/></port>
+</ports>
+</host>
+</nmaprun>
+`
+
+const harArchive = `{
+  "log": {
+    "version": "1.2",
+    "entries": [
+      {"request": {"method": "GET", "url": "https://gateway.example.com/api/users?id=1"}},
+      {"request": {"method": "POST", "url": "https://gateway.example.com:443/api/orders"}},
+      {"request": {"method": "GET", "url": "http://legacy.example.com:8080/"}},
+      {"request": {"method": "GET", "url": "data:image/png;base64,AAAA"}}
+    ]
+  }
+}`
+
+const burpExport = `<?xml version="1.0"?>
+<!DOCTYPE items [
+<!ELEMENT items (item*)>
+]>
+<items burpVersion="2.1" exportTime="Mon Mar 04 10:00:00 CET 2019">
+  <item>
+    <time>Mon Mar 04 09:58:00 CET 2019</time>
+    <url><![CDATA[https://gateway.example.com/api/users]]></url>
+    <host ip="10.0.0.1">gateway.example.com</host>
+    <port>443</port>
+    <protocol>https</protocol>
+    <method><![CDATA[GET]]></method>
+  </item>
+  <item>
+    <url><![CDATA[https://gateway.example.com/admin]]></url>
+    <host ip="10.0.0.1">gateway.example.com</host>
+    <port>443</port>
+    <protocol>https</protocol>
+  </item>
+  <item>
+    <url><![CDATA[http://legacy.example.com:8080/]]></url>
+    <host ip="10.0.0.2">legacy.example.com</host>
+    <port>8080</port>
+    <protocol>http</protocol>
+  </item>
+</items>
+`
+
+func TestRead(t *testing.T) {
+	tests := []struct {
+		name    string
+		input   string
+		format  Format
+		want    []string
+		wantErr bool
+	}{
+		{
+			name:   "list",
+			input:  "example.com\n10.0.0.0/24\n",
+			format: FormatAuto,
+			want:   []string{"example.com", "10.0.0.0/24"},
+		},
+		{
+			name:   "nmap",
+			input:  nmapReport,
+			format: FormatAuto,
+			want: []string{
+				"http://gateway.example.com",
+				"https://gateway.example.com",
+				"https://10.0.0.2:8443",
+				"http://10.0.0.2:8080",
+			},
+		},
+		{
+			name:   "har",
+			input:  harArchive,
+			format: FormatAuto,
+			want:   []string{"https://gateway.example.com", "http://legacy.example.com:8080"},
+		},
+		{
+			name:   "burp",
+			input:  burpExport,
+			format: FormatBurp,
+			want:   []string{"https://gateway.example.com", "http://legacy.example.com:8080"},
+		},
+		{
+			name:    "malformedHAR",
+			input:   `{"log": [`,
+			format:  FormatHAR,
+			wantErr: true,
+		},
+		{
+			name:    "unknownFormat",
+			input:   "example.com",
+			format:  Format("xlsx"),
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Read(strings.NewReader(tt.input), tt.format)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error mismatch: got error %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("targets mismatch: got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
@@ -0,0 +1,301 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+// Package targets expands the specifications of the targets to scan, such as
+// hosts, host:port pairs or CIDR ranges, into the base URLs of the services
+// to scan, probing which of them are alive.
+package targets
+
+import (
+	"bufio"
+	"context"
+	"fmt"
+	"io"
+	"math/big"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"sync"
+)
+
+// MaxCIDRSize is the maximum number of addresses of the CIDR ranges that can be
+// expanded.
+const MaxCIDRSize = 1 << 16
+
+// DefaultPorts are the ports probed for the hosts specified without a port.
+var DefaultPorts = []int{80, 443, 8080, 8443}
+
+// Target is a service to scan. URLs contains the alternative base URLs of the
+// service, in order of preference. When Probe is true, the URLs must be probed
+// to find which of them, if any, is alive.
+type Target struct {
+	URLs  []string
+	Probe bool
+}
+
+// ReadSpecs reads a target specification per line, skipping the empty lines
+// and the lines starting with #.
+func ReadSpecs(r io.Reader) ([]string, error) {
+	var specs []string
+
+	scanner := bufio.NewScanner(r)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		specs = append(specs, line)
+	}
+
+	return specs, scanner.Err()
+}
+
+// Expand expands the target specifications. A specification can be a full
+// http or https URL, which is scanned as is, a host, a host:port pair or an
+// IPv4 or IPv6 CIDR range. The hosts specified without a port are expanded to
+// the given ports. Every host:port pair is expanded to its https and http
+// URLs, that must be probed.
+func Expand(specs []string, ports []int) ([]Target, error) {
+	var targets []Target
+
+	for _, spec := range specs {
+		ts, err := expand(spec, ports)
+		if err != nil {
+			return nil, err
+		}
+		targets = append(targets, ts...)
+	}
+
+	return targets, nil
+}
+
+func expand(spec string, ports []int) ([]Target, error) {
+	spec = strings.TrimSpace(spec)
+
+	if strings.Contains(spec, "://") {
+		u, err := url.Parse(spec)
+		if err != nil {
+			return nil, fmt.Errorf("invalid target %q: %w", spec, err)
+		}
+		if u.Scheme != "http" && u.Scheme != "https" {
+			return nil, fmt.Errorf("invalid target %q: unsupported scheme %q", spec, u.Scheme)
+		}
+		return []Target{{URLs: []string{strings.TrimSuffix(spec, "/")}}}, nil
+	}
+
+	var hosts []string
+	port := 0
+
+	if strings.Contains(spec, "/") {
+		addrs, err := expandCIDR(spec)
+		if err != nil {
+			return nil, err
+		}
+		hosts = addrs
+	} else {
+		host, p, err := splitHostPort(spec)
+		if err != nil {
+			return nil, err
+		}
+		hosts = []string{host}
+		port = p
+	}
+
+	hostPorts := ports
+	if port != 0 {
+		hostPorts = []int{port}
+	}
+
+	var targets []Target
+	for _, h := range hosts {
+		for _, p := range hostPorts {
+			targets = append(targets, Target{
+				URLs:  []string{BaseURL("https", h, p), BaseURL("http", h, p)},
+				Probe: true,
+			})
+		}
+	}
+
+	return targets, nil
+}
+
+// splitHostPort splits a host or host:port specification. The returned port
+// is 0 if the specification has no port.
+func splitHostPort(spec string) (host string, port int, err error) {
+	// Bare IPv6 addresses, that can not be split.
+	if ip := net.ParseIP(spec); ip != nil {
+		return spec, 0, nil
+	}
+
+	host, sport, err := net.SplitHostPort(spec)
+	if err != nil {
+		host = strings.TrimSuffix(strings.TrimPrefix(spec, "["), "]")
+		if host == "" || strings.ContainsAny(host, ":[]") {
+			return "", 0, fmt.Errorf("invalid target %q", spec)
+		}
+		return host, 0, nil
+	}
+
+	port, err = strconv.Atoi(sport)
+	if err != nil || port < 1 || port > 65535 {
+		return "", 0, fmt.Errorf("invalid target %q: invalid port %q", spec, sport)
+	}
+	if host == "" {
+		return "", 0, fmt.Errorf("invalid target %q: empty host", spec)
+	}
+
+	return host, port, nil
+}
+
+// expandCIDR returns the host addresses of a CIDR range. The network and
+// broadcast addresses of the IPv4 ranges bigger than /31 are skipped.
+func expandCIDR(spec string) ([]string, error) {
+	ip, ipnet, err := net.ParseCIDR(spec)
+	if err != nil {
+		return nil, fmt.Errorf("invalid target %q: %w", spec, err)
+	}
+
+	ones, bits := ipnet.Mask.Size()
+	if bits-ones > 16 || 1<<uint(bits-ones) > MaxCIDRSize {
+		return nil, fmt.Errorf("invalid target %q: CIDR ranges bigger than %v addresses are not supported", spec, MaxCIDRSize)
+	}
+	size := 1 << uint(bits-ones)
+
+	start := new(big.Int).SetBytes(ipnet.IP)
+	length := len(ipnet.IP)
+	if ip.To4() != nil {
+		length = net.IPv4len
+	}
+
+	var addrs []string
+	for i := 0; i < size; i++ {
+		if ip.To4() != nil && size > 2 && (i == 0 || i == size-1) {
+			continue
+		}
+
+		n := new(big.Int).Add(start, big.NewInt(int64(i)))
+		b := n.Bytes()
+		addr := make(net.IP, length)
+		copy(addr[length-len(b):], b)
+
+		addrs = append(addrs, addr.String())
+	}
+
+	return addrs, nil
+}
+
+// BaseURL returns the base URL of a service, omitting the port when it is
+// the default for the scheme.
+func BaseURL(scheme, host string, port int) string {
+	if (scheme == "http" && port == 80) || (scheme == "https" && port == 443) {
+		if strings.Contains(host, ":") {
+			host = "[" + host + "]"
+		}
+		return scheme + "://" + host
+	}
+
+	return scheme + "://" + net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+// Unreachable is a probed target that did not answer. URL is the first of its
+// URLs, and Err the error of the last probe.
+type Unreachable struct {
+	URL string
+	Err error
+}
+
+// Probe returns the base URLs of the targets to scan, without duplicates, and
+// the targets that did not answer the probes. The targets that must be probed
+// are requested with the given client, using at most concurrency goroutines,
+// and only the first of their URLs that answers is returned. The targets that
+// don't need to be probed are always returned.
+func Probe(ctx context.Context, client *http.Client, targets []Target, concurrency int) ([]string, []Unreachable) {
+	if concurrency < 1 {
+		concurrency = 1
+	}
+
+	found := make([]string, len(targets))
+	errs := make([]error, len(targets))
+
+	var wg sync.WaitGroup
+	rate := make(chan struct{}, concurrency)
+
+	for i, t := range targets {
+		if !t.Probe {
+			found[i] = t.URLs[0]
+			continue
+		}
+
+		rate <- struct{}{}
+		wg.Add(1)
+
+		go func(i int, t Target) {
+			defer func() {
+				<-rate
+				wg.Done()
+			}()
+
+			for _, u := range t.URLs {
+				err := alive(ctx, client, u)
+				if err == nil {
+					found[i] = u
+					return
+				}
+				errs[i] = err
+			}
+		}(i, t)
+	}
+
+	wg.Wait()
+
+	var (
+		unreachable []Unreachable
+		seen        = make(map[string]bool)
+	)
+	for i, t := range targets {
+		if found[i] != "" || seen[t.URLs[0]] {
+			continue
+		}
+		seen[t.URLs[0]] = true
+		unreachable = append(unreachable, Unreachable{URL: t.URLs[0], Err: errs[i]})
+	}
+
+	return Dedup(found), unreachable
+}
+
+// alive returns nil if the URL answers an HTTP request, whatever the status
+// code is, or the error of the request otherwise.
+func alive(ctx context.Context, client *http.Client, URL string) error {
+	req, err := http.NewRequestWithContext(ctx, "GET", URL+"/", nil)
+	if err != nil {
+		return err
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+
+	return nil
+}
+
+// Dedup returns the non empty URLs, in the same order and without
+// duplicates.
+func Dedup(urls []string) []string {
+	seen := make(map[string]bool)
+
+	var unique []string
+	for _, u := range urls {
+		if u == "" || seen[u] {
+			continue
+		}
+		seen[u] = true
+		unique = append(unique, u)
+	}
+
+	return unique
+}
@@ -0,0 +1,161 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package targets
+
+import (
+	"context"
+	"crypto/tls"
+	"net/http"
+	"net/http/httptest"
+	"reflect"
+	"strings"
+	"testing"
+	"time"
+)
+
+func TestExpand(t *testing.T) {
+	tests := []struct {
+		name    string
+		specs   []string
+		ports   []int
+		want    []Target
+		wantErr bool
+	}{
+		{
+			name:  "url",
+			specs: []string{"http://example.com:8080/"},
+			ports: DefaultPorts,
+			want:  []Target{{URLs: []string{"http://example.com:8080"}}},
+		},
+		{
+			name:  "host",
+			specs: []string{"example.com"},
+			ports: []int{80, 8443},
+			want: []Target{
+				{URLs: []string{"https://example.com:80", "http://example.com"}, Probe: true},
+				{URLs: []string{"https://example.com:8443", "http://example.com:8443"}, Probe: true},
+			},
+		},
+		{
+			name:  "hostPort",
+			specs: []string{"example.com:443"},
+			ports: DefaultPorts,
+			want: []Target{
+				{URLs: []string{"https://example.com", "http://example.com:443"}, Probe: true},
+			},
+		},
+		{
+			name:  "ipv6",
+			specs: []string{"::1", "[::1]:8080"},
+			ports: []int{443},
+			want: []Target{
+				{URLs: []string{"https://[::1]", "http://[::1]:443"}, Probe: true},
+				{URLs: []string{"https://[::1]:8080", "http://[::1]:8080"}, Probe: true},
+			},
+		},
+		{
+			name:  "ipv4CIDR",
+			specs: []string{"10.0.0.1/30"},
+			ports: []int{8080},
+			want: []Target{
+				{URLs: []string{"https://10.0.0.1:8080", "http://10.0.0.1:8080"}, Probe: true},
+				{URLs: []string{"https://10.0.0.2:8080", "http://10.0.0.2:8080"}, Probe: true},
+			},
+		},
+		{
+			name:  "ipv6CIDR",
+			specs: []string{"fd00::/127"},
+			ports: []int{8080},
+			want: []Target{
+				{URLs: []string{"https://[fd00::]:8080", "http://[fd00::]:8080"}, Probe: true},
+				{URLs: []string{"https://[fd00::1]:8080", "http://[fd00::1]:8080"}, Probe: true},
+			},
+		},
+		{
+			name:    "bigCIDR",
+			specs:   []string{"10.0.0.0/8"},
+			ports:   DefaultPorts,
+			wantErr: true,
+		},
+		{
+			name:    "badScheme",
+			specs:   []string{"ftp://example.com"},
+			ports:   DefaultPorts,
+			wantErr: true,
+		},
+		{
+			name:    "badPort",
+			specs:   []string{"example.com:99999"},
+			ports:   DefaultPorts,
+			wantErr: true,
+		},
+	}
+
+	for _, tt := range tests {
+		t.Run(tt.name, func(t *testing.T) {
+			got, err := Expand(tt.specs, tt.ports)
+			if (err != nil) != tt.wantErr {
+				t.Fatalf("error mismatch: got error %v, want error %v", err, tt.wantErr)
+			}
+			if !reflect.DeepEqual(got, tt.want) {
+				t.Errorf("targets mismatch: got %v, want %v", got, tt.want)
+			}
+		})
+	}
+}
+
+func TestReadSpecs(t *testing.T) {
+	in := "example.com\n\n# comment\n  10.0.0.0/24  \n"
+
+	got, err := ReadSpecs(strings.NewReader(in))
+	if err != nil {
+		t.Fatalf("unexpected error: %v", err)
+	}
+
+	want := []string{"example.com", "10.0.0.0/24"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("specs mismatch: got %v, want %v", got, want)
+	}
+}
+
+func TestProbe(t *testing.T) {
+	handler := http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.NotFound(w, r)
+	})
+
+	plain := httptest.NewServer(handler)
+	defer plain.Close()
+
+	secure := httptest.NewTLSServer(handler)
+	defer secure.Close()
+
+	plainHost := strings.TrimPrefix(plain.URL, "http://")
+	secureHost := strings.TrimPrefix(secure.URL, "https://")
+
+	targets := []Target{
+		{URLs: []string{"https://" + plainHost, "http://" + plainHost}, Probe: true},
+		{URLs: []string{"https://" + secureHost, "http://" + secureHost}, Probe: true},
+		{URLs: []string{"https://127.0.0.1:1", "http://127.0.0.1:1"}, Probe: true},
+		{URLs: []string{"http://unprobed.example.com"}},
+		{URLs: []string{"https://" + secureHost, "http://" + secureHost}, Probe: true},
+	}
+
+	client := &http.Client{
+		Timeout: 5 * time.Second,
+		Transport: &http.Transport{
+			TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+		},
+	}
+
+	got, unreachable := Probe(context.Background(), client, targets, 2)
+
+	want := []string{plain.URL, secure.URL, "http://unprobed.example.com"}
+	if !reflect.DeepEqual(got, want) {
+		t.Errorf("URLs mismatch: got %v, want %v", got, want)
+	}
+	if len(unreachable) != 1 || unreachable[0].URL != "https://127.0.0.1:1" || unreachable[0].Err == nil {
+		t.Errorf("unreachable target expected: https://127.0.0.1:1, got %v", unreachable)
+	}
+}
@@ -0,0 +1,126 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package gozuul
+
+import (
+	"context"
+	"errors"
+	"fmt"
+)
+
+// Verdict is the outcome of a passive or active scan.
+type Verdict int
+
+const (
+	// VerdictUnknown is the zero value. A scan never finishes with it.
+	VerdictUnknown Verdict = iota
+	// VerdictNotVulnerable means the target is a Zuul instance that does not
+	// accept filter uploads.
+	VerdictNotVulnerable
+	// VerdictVulnerable means the target accepts the upload of filters, or
+	// they have been proved to run in it.
+	VerdictVulnerable
+	// VerdictPossiblyVulnerable means the target might be vulnerable, but it
+	// could not be confirmed.
+	VerdictPossiblyVulnerable
+	// VerdictAdminDisabled means that POSTing to the filter upload endpoint is
+	// forbidden.
+	VerdictAdminDisabled
+	// VerdictAuthRequired means that the admin endpoints require
+	// authentication.
+	VerdictAuthRequired
+	// VerdictNotZuul means that the target does not expose the Zuul admin
+	// endpoints.
+	VerdictNotZuul
+	// VerdictUnreachable means that the target could not be reached.
+	VerdictUnreachable
+	// VerdictInconclusive means that the scan could not reach any conclusion.
+	VerdictInconclusive
+)
+
+var verdictNames = map[Verdict]string{
+	VerdictUnknown:            "unknown",
+	VerdictNotVulnerable:      "not_vulnerable",
+	VerdictVulnerable:         "vulnerable",
+	VerdictPossiblyVulnerable: "possibly_vulnerable",
+	VerdictAdminDisabled:      "admin_disabled",
+	VerdictAuthRequired:       "auth_required",
+	VerdictNotZuul:            "not_zuul",
+	VerdictUnreachable:        "unreachable",
+	VerdictInconclusive:       "inconclusive",
+}
+
+// Verdicts returns all the verdicts a scan can finish with, in order.
+func Verdicts() []Verdict {
+	return []Verdict{
+		VerdictNotVulnerable,
+		VerdictVulnerable,
+		VerdictPossiblyVulnerable,
+		VerdictAdminDisabled,
+		VerdictAuthRequired,
+		VerdictNotZuul,
+		VerdictUnreachable,
+		VerdictInconclusive,
+	}
+}
+
+func (v Verdict) String() string {
+	if name, ok := verdictNames[v]; ok {
+		return name
+	}
+	return fmt.Sprintf("Verdict(%d)", int(v))
+}
+
+// MarshalText implements the encoding.TextMarshaler interface.
+func (v Verdict) MarshalText() ([]byte, error) {
+	if _, ok := verdictNames[v]; !ok {
+		return nil, fmt.Errorf("invalid verdict: %d", int(v))
+	}
+	return []byte(v.String()), nil
+}
+
+// UnmarshalText implements the encoding.TextUnmarshaler interface.
+func (v *Verdict) UnmarshalText(text []byte) error {
+	for verdict, name := range verdictNames {
+		if name == string(text) {
+			*v = verdict
+			return nil
+		}
+	}
+	return fmt.Errorf("invalid verdict: %q", text)
+}
+
+// setVerdict sets the verdict and reason of the ResultSet, keeping the legacy
+// booleans in sync.
+func (rs *ResultSet) setVerdict(v Verdict, reason string) {
+	rs.Verdict = v
+	rs.Reason = reason
+
+	switch v {
+	case VerdictVulnerable:
+		rs.Vulnerable = true
+	case VerdictPossiblyVulnerable:
+		rs.MightVulnerable = true
+	case VerdictAdminDisabled:
+		rs.AdminDisabled = true
+	}
+}
+
+// setErrorVerdict sets the verdict of a ResultSet whose scan finished with an
+// error, unless a verdict was already reached before the error happened.
+func (rs *ResultSet) setErrorVerdict(err error) {
+	if err == nil || rs.Verdict != VerdictUnknown {
+		return
+	}
+
+	switch {
+	case errors.Is(err, context.Canceled), errors.Is(err, context.DeadlineExceeded):
+		rs.setVerdict(VerdictInconclusive, fmt.Sprintf("scan aborted: %v", err))
+	case errors.Is(err, ErrNetwork):
+		rs.setVerdict(VerdictUnreachable, err.Error())
+	default:
+		rs.setVerdict(VerdictInconclusive, err.Error())
+	}
+}
@@ -0,0 +1,54 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package gozuul
+
+import (
+	"encoding/json"
+	"net/http/httptest"
+	"testing"
+)
+
+func TestVerdictJSON(t *testing.T) {
+	for _, v := range Verdicts() {
+		b, err := json.Marshal(ResultSet{Verdict: v})
+		if err != nil {
+			t.Fatalf("(%v) nil error expected, got: %v", v, err)
+		}
+
+		var rs ResultSet
+		if err := json.Unmarshal(b, &rs); err != nil {
+			t.Fatalf("(%v) nil error expected, got: %v", v, err)
+		}
+		if rs.Verdict != v {
+			t.Errorf("verdict expected: %v, got: %v", v, rs.Verdict)
+		}
+	}
+
+	if _, err := json.Marshal(Verdict(-1)); err == nil {
+		t.Errorf("error expected marshaling an invalid verdict")
+	}
+
+	var v Verdict
+	if err := json.Unmarshal([]byte(`"maybe"`), &v); err == nil {
+		t.Errorf("error expected unmarshaling an invalid verdict")
+	}
+}
+
+func TestUnreachableVerdict(t *testing.T) {
+	ts := httptest.NewServer(nil)
+	URL := ts.URL
+	ts.Close()
+
+	rs, err := PassiveScan(URL)
+	if err == nil {
+		t.Errorf("error expected, got: %v", err)
+	}
+	if rs.Verdict != VerdictUnreachable {
+		t.Errorf("verdict expected: %v, got: %v", VerdictUnreachable, rs.Verdict)
+	}
+	if rs.Reason == "" {
+		t.Errorf("reason expected, got: %q", rs.Reason)
+	}
+}
@@ -0,0 +1,382 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+// Package watch polls the filter loaders of Zuul instances and reports the
+// filters and revisions that appear in them, such as the ones uploaded
+// through the script manager.
+package watch
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"os"
+	"path/filepath"
+	"sort"
+	"sync"
+	"time"
+
+	gozuul "github.com/adevinta/gozuul"
+)
+
+// Types of events.
+const (
+	// EventNewFilter is sent when a filter ID appears in a target.
+	EventNewFilter = "new_filter"
+	// EventNewRevision is sent when new revisions of a known filter appear
+	// in a target.
+	EventNewRevision = "new_revision"
+)
+
+// concurrency is the maximum number of targets polled at the same time.
+const concurrency = 30
+
+// Event reports the revisions of a filter that appeared in a target.
+type Event struct {
+	Time      time.Time `json:"time"`
+	Target    string    `json:"target"`
+	Type      string    `json:"type"`
+	ID        string    `json:"id"`
+	Revisions []int     `json:"revisions"`
+}
+
+// State maps every target polled to the revisions of its filters seen so
+// far, by filter ID.
+type State map[string]map[string][]int
+
+// ReadState reads the state stored in path. A missing file is read as an
+// empty state.
+func ReadState(path string) (State, error) {
+	st := make(State)
+
+	b, err := ioutil.ReadFile(path)
+	if os.IsNotExist(err) {
+		return st, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	if err := json.Unmarshal(b, &st); err != nil {
+		return nil, fmt.Errorf("unable to read state file %v: %w", path, err)
+	}
+
+	return st, nil
+}
+
+// Write stores the state in path, replacing it atomically.
+func (st State) Write(path string) error {
+	b, err := json.MarshalIndent(st, "", "  ")
+	if err != nil {
+		return err
+	}
+
+	f, err := ioutil.TempFile(filepath.Dir(path), filepath.Base(path)+".tmp")
+	if err != nil {
+		return err
+	}
+	defer os.Remove(f.Name())
+
+	if _, err := f.Write(append(b, '\n')); err != nil {
+		f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	return os.Rename(f.Name(), path)
+}
+
+// Sink receives the events of a Watcher.
+type Sink interface {
+	Send(ctx context.Context, e Event) error
+}
+
+// WriterSink writes every event as a line of JSON.
+type WriterSink struct {
+	mu  sync.Mutex
+	enc *json.Encoder
+}
+
+// NewWriterSink returns a WriterSink writing to w.
+func NewWriterSink(w io.Writer) *WriterSink {
+	return &WriterSink{enc: json.NewEncoder(w)}
+}
+
+// Send writes the event.
+func (s *WriterSink) Send(ctx context.Context, e Event) error {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+
+	return s.enc.Encode(e)
+}
+
+// WebhookSink posts every event as JSON to URL. If Client is nil,
+// http.DefaultClient is used.
+type WebhookSink struct {
+	URL    string
+	Client *http.Client
+}
+
+// Send posts the event, and fails unless the webhook answers with a 2xx
+// status code.
+func (s *WebhookSink) Send(ctx context.Context, e Event) error {
+	b, err := json.Marshal(e)
+	if err != nil {
+		return err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, "POST", s.URL, bytes.NewReader(b))
+	if err != nil {
+		return err
+	}
+	req.Header.Set("Content-Type", "application/json")
+
+	client := s.Client
+	if client == nil {
+		client = http.DefaultClient
+	}
+
+	res, err := client.Do(req)
+	if err != nil {
+		return err
+	}
+	defer res.Body.Close()
+	io.Copy(ioutil.Discard, res.Body)
+
+	if res.StatusCode < 200 || res.StatusCode > 299 {
+		return fmt.Errorf("unexpected status code from webhook %v: %v", s.URL, res.StatusCode)
+	}
+
+	return nil
+}
+
+// Watcher polls the filter loaders of Targets every Interval, and sends an
+// event to every sink of Sinks when a filter ID or revision appears. The
+// filters found the first time a target is polled are recorded without
+// sending events. The revisions of a target are only recorded once every sink
+// has accepted its events, so the events that can not be sent are sent again
+// on the next poll, possibly twice to the sinks that accepted them. If
+// StatePath is set, the revisions recorded are stored in it after every poll,
+// and read from it before the first one, so the events are not sent again
+// when the Watcher is restarted. The targets that can not be polled and the
+// events that can not be sent are logged to ErrorLog, or to the standard
+// logger if it is nil. The default Scanner of gozuul is used if Scanner is
+// nil.
+type Watcher struct {
+	Scanner   *gozuul.Scanner
+	Targets   []string
+	Interval  time.Duration
+	StatePath string
+	Sinks     []Sink
+	ErrorLog  *log.Logger
+
+	state State
+}
+
+// Run polls the targets right away and then every Interval, until the given
+// context is cancelled, returning its error. The errors of every poll are
+// logged without stopping the Watcher, and only an error reading the state
+// before the first poll is returned.
+func (w *Watcher) Run(ctx context.Context) error {
+	if err := w.readState(); err != nil {
+		return err
+	}
+
+	ticker := time.NewTicker(w.Interval)
+	defer ticker.Stop()
+
+	for {
+		if _, err := w.Poll(ctx); err != nil {
+			w.logf("unable to poll the targets: %v", err)
+		}
+
+		select {
+		case <-ctx.Done():
+			return ctx.Err()
+		case <-ticker.C:
+		}
+	}
+}
+
+// targetPoll is the outcome of polling a target: the revisions of its
+// filters to record in the state and the events to send.
+type targetPoll struct {
+	target string
+	seen   map[string][]int
+	events []Event
+}
+
+// Poll polls every target once, sends the events to the sinks and stores the
+// state, returning the events accepted by every sink.
+func (w *Watcher) Poll(ctx context.Context) ([]Event, error) {
+	if err := w.readState(); err != nil {
+		return nil, err
+	}
+
+	var (
+		mu    sync.Mutex
+		wg    sync.WaitGroup
+		rate  = make(chan struct{}, concurrency)
+		polls []targetPoll
+	)
+	for _, target := range w.Targets {
+		rate <- struct{}{}
+		wg.Add(1)
+
+		go func(target string) {
+			defer func() {
+				<-rate
+				wg.Done()
+			}()
+
+			filters, err := w.listFilters(ctx, target)
+			if err != nil {
+				if ctx.Err() == nil {
+					w.logf("%v: %v", target, err)
+				}
+				return
+			}
+
+			mu.Lock()
+			defer mu.Unlock()
+
+			seen, events := w.update(target, filters)
+			polls = append(polls, targetPoll{target: target, seen: seen, events: events})
+		}(target)
+	}
+	wg.Wait()
+
+	sort.Slice(polls, func(i, j int) bool {
+		return polls[i].target < polls[j].target
+	})
+
+	var events []Event
+	for _, p := range polls {
+		if !w.send(ctx, p.events) {
+			continue
+		}
+		w.state[p.target] = p.seen
+		events = append(events, p.events...)
+	}
+
+	if w.StatePath != "" {
+		if err := w.state.Write(w.StatePath); err != nil {
+			return events, err
+		}
+	}
+
+	return events, nil
+}
+
+// readState reads the state from StatePath, unless it has already been read.
+func (w *Watcher) readState() error {
+	if w.state != nil {
+		return nil
+	}
+
+	st := make(State)
+	if w.StatePath != "" {
+		var err error
+		if st, err = ReadState(w.StatePath); err != nil {
+			return err
+		}
+	}
+	w.state = st
+
+	return nil
+}
+
+// send sends the events to every sink, and reports whether all of them
+// accepted every event.
+func (w *Watcher) send(ctx context.Context, events []Event) bool {
+	ok := true
+	for _, e := range events {
+		for _, s := range w.Sinks {
+			if err := s.Send(ctx, e); err != nil {
+				w.logf("%v: unable to send event: %v", e.Target, err)
+				ok = false
+			}
+		}
+	}
+	return ok
+}
+
+// update returns the revisions of the filters of the target to record in the
+// state, and the events for the filters and revisions not seen before. The
+// state itself is not modified.
+func (w *Watcher) update(target string, filters []gozuul.Filter) (map[string][]int, []Event) {
+	prev, known := w.state[target]
+
+	seen := make(map[string][]int, len(prev))
+	for id, revs := range prev {
+		seen[id] = revs
+	}
+
+	var (
+		events []Event
+		now    = time.Now().UTC()
+	)
+	for _, f := range filters {
+		revs, ok := seen[f.ID]
+		revs = append([]int(nil), revs...)
+
+		var added []int
+		for _, r := range f.Revisions {
+			if !contains(revs, r.Revision) {
+				added = append(added, r.Revision)
+				revs = append(revs, r.Revision)
+			}
+		}
+		if len(added) == 0 {
+			continue
+		}
+		sort.Ints(revs)
+		seen[f.ID] = revs
+
+		if !known {
+			continue
+		}
+
+		e := Event{Time: now, Target: target, Type: EventNewRevision, ID: f.ID, Revisions: added}
+		if !ok {
+			e.Type = EventNewFilter
+		}
+		events = append(events, e)
+	}
+
+	return seen, events
+}
+
+// listFilters lists the filters of the target with the Scanner of the
+// Watcher, or with the default one if it is nil.
+func (w *Watcher) listFilters(ctx context.Context, target string) ([]gozuul.Filter, error) {
+	if w.Scanner == nil {
+		return gozuul.ListFiltersContext(ctx, target)
+	}
+	return w.Scanner.ListFiltersContext(ctx, target)
+}
+
+func (w *Watcher) logf(format string, v ...interface{}) {
+	if w.ErrorLog != nil {
+		w.ErrorLog.Printf(format, v...)
+		return
+	}
+	log.Printf(format, v...)
+}
+
+func contains(revs []int, rev int) bool {
+	for _, r := range revs {
+		if r == rev {
+			return true
+		}
+	}
+	return false
+}
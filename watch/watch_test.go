@@ -0,0 +1,337 @@
+/*
+Copyright 2019 Adevinta
+*/
+
+package watch
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"log"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"reflect"
+	"strings"
+	"sync"
+	"testing"
+	"time"
+
+	gozuul "github.com/adevinta/gozuul"
+)
+
+const filterLink = `<a href="scriptmanager?action=DOWNLOAD&filter_id=%v&revision=%v">DOWNLOAD</a>`
+
+// filterLoader serves a filter loader page listing the given filters, which
+// can be changed between polls.
+type filterLoader struct {
+	mu      sync.Mutex
+	filters map[string][]int
+}
+
+func (fl *filterLoader) set(filters map[string][]int) {
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+	fl.filters = filters
+}
+
+func (fl *filterLoader) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	if r.URL.Path != "/admin/filterLoader.jsp" {
+		http.NotFound(w, r)
+		return
+	}
+
+	fl.mu.Lock()
+	defer fl.mu.Unlock()
+	for id, revs := range fl.filters {
+		for _, rev := range revs {
+			fmt.Fprintf(w, filterLink, id, rev)
+		}
+	}
+}
+
+type event struct {
+	typ  string
+	id   string
+	revs []int
+}
+
+func simplify(events []Event) []event {
+	var es []event
+	for _, e := range events {
+		es = append(es, event{e.Type, e.ID, e.Revisions})
+	}
+	return es
+}
+
+func TestPoll(t *testing.T) {
+	fl := &filterLoader{}
+	ts := httptest.NewServer(fl)
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "gozuul")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	statePath := filepath.Join(dir, "state.json")
+
+	newWatcher := func() *Watcher {
+		return &Watcher{
+			Scanner:   gozuul.NewScanner(),
+			Targets:   []string{ts.URL},
+			StatePath: statePath,
+		}
+	}
+
+	polls := []struct {
+		name    string
+		filters map[string][]int
+		want    []event
+	}{
+		{
+			name:    "first",
+			filters: map[string][]int{"origin:Routing:route": {1, 2}},
+		},
+		{
+			name:    "unchanged",
+			filters: map[string][]int{"origin:Routing:route": {1, 2}},
+		},
+		{
+			name:    "newFilter",
+			filters: map[string][]int{"origin:Routing:route": {1, 2}, "origin:Vulncheck:pre": {1}},
+			want:    []event{{EventNewFilter, "origin:Vulncheck:pre", []int{1}}},
+		},
+		{
+			name:    "newRevision",
+			filters: map[string][]int{"origin:Routing:route": {2, 3, 4}, "origin:Vulncheck:pre": {1}},
+			want:    []event{{EventNewRevision, "origin:Routing:route", []int{3, 4}}},
+		},
+	}
+
+	w := newWatcher()
+	for _, p := range polls {
+		fl.set(p.filters)
+
+		events, err := w.Poll(context.Background())
+		if err != nil {
+			t.Fatalf("%v: nil error expected, got %v", p.name, err)
+		}
+		if got := simplify(events); !reflect.DeepEqual(got, p.want) {
+			t.Errorf("%v: events expected: %v, got: %v", p.name, p.want, got)
+		}
+	}
+
+	// A new watcher reads the revisions seen from the state file.
+	fl.set(map[string][]int{"origin:Routing:route": {2, 3, 4, 5}, "origin:Vulncheck:pre": {1}})
+	var b strings.Builder
+	w = newWatcher()
+	w.Sinks = []Sink{NewWriterSink(&b)}
+	events, err := w.Poll(context.Background())
+	if err != nil {
+		t.Fatalf("nil error expected, got %v", err)
+	}
+	want := []event{{EventNewRevision, "origin:Routing:route", []int{5}}}
+	if got := simplify(events); !reflect.DeepEqual(got, want) {
+		t.Errorf("events expected: %v, got: %v", want, got)
+	}
+
+	var e Event
+	if err := json.Unmarshal([]byte(b.String()), &e); err != nil || e.Target != ts.URL || e.ID != "origin:Routing:route" {
+		t.Errorf("event expected in the writer sink, got: %q", b.String())
+	}
+}
+
+func TestPollDefaultScanner(t *testing.T) {
+	fl := &filterLoader{}
+	fl.set(map[string][]int{"origin:Routing:route": {1}})
+	ts := httptest.NewServer(fl)
+	defer ts.Close()
+
+	w := &Watcher{Targets: []string{ts.URL}}
+
+	if _, err := w.Poll(context.Background()); err != nil {
+		t.Fatalf("nil error expected, got %v", err)
+	}
+
+	fl.set(map[string][]int{"origin:Routing:route": {1, 2}})
+	events, err := w.Poll(context.Background())
+	if err != nil {
+		t.Fatalf("nil error expected, got %v", err)
+	}
+	want := []event{{EventNewRevision, "origin:Routing:route", []int{2}}}
+	if got := simplify(events); !reflect.DeepEqual(got, want) {
+		t.Errorf("events expected: %v, got: %v", want, got)
+	}
+}
+
+func TestPollError(t *testing.T) {
+	ts := httptest.NewServer(http.NotFoundHandler())
+	defer ts.Close()
+
+	var b strings.Builder
+	w := &Watcher{
+		Scanner:  gozuul.NewScanner(),
+		Targets:  []string{ts.URL},
+		ErrorLog: log.New(&b, "", 0),
+	}
+
+	events, err := w.Poll(context.Background())
+	if err != nil || len(events) != 0 {
+		t.Fatalf("no events and nil error expected, got %v, %v", events, err)
+	}
+	if !strings.HasPrefix(b.String(), ts.URL+": ") {
+		t.Errorf("error of the target expected in the log, got: %q", b.String())
+	}
+}
+
+// flakySink fails to send the events while fail is set, and records the
+// events sent otherwise.
+type flakySink struct {
+	fail   bool
+	events []Event
+}
+
+func (s *flakySink) Send(ctx context.Context, e Event) error {
+	if s.fail {
+		return errors.New("sink unavailable")
+	}
+	s.events = append(s.events, e)
+	return nil
+}
+
+func TestPollSendError(t *testing.T) {
+	fl := &filterLoader{}
+	fl.set(map[string][]int{"origin:Routing:route": {1}})
+	ts := httptest.NewServer(fl)
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "gozuul")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+	statePath := filepath.Join(dir, "state.json")
+
+	var b strings.Builder
+	sink := &flakySink{}
+	w := &Watcher{
+		Scanner:   gozuul.NewScanner(),
+		Targets:   []string{ts.URL},
+		StatePath: statePath,
+		Sinks:     []Sink{&flakySink{}, sink},
+		ErrorLog:  log.New(&b, "", 0),
+	}
+
+	if _, err := w.Poll(context.Background()); err != nil {
+		t.Fatalf("nil error expected, got %v", err)
+	}
+
+	// The revision is not recorded while a sink fails to send its event.
+	fl.set(map[string][]int{"origin:Routing:route": {1, 2}})
+	sink.fail = true
+	events, err := w.Poll(context.Background())
+	if err != nil || len(events) != 0 {
+		t.Fatalf("no events and nil error expected, got %v, %v", events, err)
+	}
+	if !strings.Contains(b.String(), "unable to send event") {
+		t.Errorf("send error expected in the log, got: %q", b.String())
+	}
+	st, err := ReadState(statePath)
+	if err != nil {
+		t.Fatal(err)
+	}
+	if want := []int{1}; !reflect.DeepEqual(st[ts.URL]["origin:Routing:route"], want) {
+		t.Errorf("revisions expected in the state: %v, got: %v", want, st[ts.URL]["origin:Routing:route"])
+	}
+
+	sink.fail = false
+	events, err = w.Poll(context.Background())
+	if err != nil {
+		t.Fatalf("nil error expected, got %v", err)
+	}
+	want := []event{{EventNewRevision, "origin:Routing:route", []int{2}}}
+	if got := simplify(events); !reflect.DeepEqual(got, want) {
+		t.Errorf("events expected: %v, got: %v", want, got)
+	}
+	if got := simplify(sink.events); !reflect.DeepEqual(got, want) {
+		t.Errorf("events expected in the sink: %v, got: %v", want, got)
+	}
+}
+
+func TestRun(t *testing.T) {
+	fl := &filterLoader{}
+	ts := httptest.NewServer(fl)
+	defer ts.Close()
+
+	dir, err := ioutil.TempDir("", "gozuul")
+	if err != nil {
+		t.Fatal(err)
+	}
+	defer os.RemoveAll(dir)
+
+	// The state can be read, as it does not exist yet, but not written.
+	var b strings.Builder
+	w := &Watcher{
+		Scanner:   gozuul.NewScanner(),
+		Targets:   []string{ts.URL},
+		Interval:  10 * time.Millisecond,
+		StatePath: filepath.Join(dir, "missing", "state.json"),
+		ErrorLog:  log.New(&b, "", 0),
+	}
+
+	ctx, cancel := context.WithTimeout(context.Background(), 100*time.Millisecond)
+	defer cancel()
+
+	if err := w.Run(ctx); err != context.DeadlineExceeded {
+		t.Fatalf("deadline exceeded error expected, got %v", err)
+	}
+	if n := strings.Count(b.String(), "unable to poll the targets"); n < 2 {
+		t.Errorf("poll errors expected in the log, got: %q", b.String())
+	}
+
+	// An invalid state stops the Watcher before the first poll.
+	statePath := filepath.Join(dir, "state.json")
+	if err := ioutil.WriteFile(statePath, []byte("{"), 0644); err != nil {
+		t.Fatal(err)
+	}
+	w = &Watcher{
+		Scanner:   gozuul.NewScanner(),
+		Targets:   []string{ts.URL},
+		Interval:  10 * time.Millisecond,
+		StatePath: statePath,
+	}
+	if err := w.Run(context.Background()); err == nil {
+		t.Errorf("error expected for invalid state")
+	}
+}
+
+func TestWebhookSink(t *testing.T) {
+	var got Event
+	ts := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != "/" || r.Method != "POST" || r.Header.Get("Content-Type") != "application/json" {
+			http.Error(w, "", http.StatusBadRequest)
+			return
+		}
+		json.NewDecoder(r.Body).Decode(&got)
+	}))
+	defer ts.Close()
+
+	e := Event{Target: "http://test.example.com", Type: EventNewFilter, ID: "origin:Vulncheck:pre", Revisions: []int{1}}
+	s := &WebhookSink{URL: ts.URL}
+	if err := s.Send(context.Background(), e); err != nil {
+		t.Fatalf("nil error expected, got %v", err)
+	}
+	if !reflect.DeepEqual(got, e) {
+		t.Errorf("event expected: %+v, got: %+v", e, got)
+	}
+
+	s.URL = ts.URL + "/unknown"
+	if err := s.Send(context.Background(), e); err == nil {
+		t.Errorf("error expected for unexpected status code")
+	}
+}